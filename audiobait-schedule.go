@@ -0,0 +1,246 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	SunriseTimeRef = "sunrise"
+	SunsetTimeRef  = "sunset"
+
+	MinAudioBaitVolume = 1
+	MaxAudioBaitVolume = 10
+
+	// audioBaitLookAheadDays is how many days ahead NextEvent searches.
+	audioBaitLookAheadDays = 2
+)
+
+var ErrNoAudioBaitEvent = errors.New("no audio bait event scheduled")
+
+// AudioBaitSchedule plays a sound, from the audio bait directory, between
+// Start and Stop each day. Start and Stop are either a time of day ("21:30")
+// or relative to the sun ("sunset", "sunrise-30m", "sunset+1h"). If Stop is
+// not after Start the window runs over midnight. The sound is played at Start
+// then every Interval until Stop, or only once if Interval is 0. Each play is
+// delayed by a random amount less than Jitter.
+type AudioBaitSchedule struct {
+	Name     string        `mapstructure:"name"`
+	Sound    string        `mapstructure:"sound"`
+	Volume   int           `mapstructure:"volume"`
+	Start    string        `mapstructure:"start"`
+	Stop     string        `mapstructure:"stop"`
+	Interval time.Duration `mapstructure:"interval"`
+	Jitter   time.Duration `mapstructure:"jitter"`
+}
+
+// AudioBaitEvent is a single playback of a sound.
+type AudioBaitEvent struct {
+	Schedule string
+	Sound    string // Path of the sound file.
+	Volume   int
+	Time     time.Time
+}
+
+// NextEvent returns the first playback at or after now, from any schedule.
+// Times of day are in now's location, and sun relative times use the
+// latitude and longitude of loc. The jitter is derived from the schedule name
+// and play time, so repeated calls give the same result.
+func (ab AudioBait) NextEvent(now time.Time, loc Location) (AudioBaitEvent, error) {
+	var next AudioBaitEvent
+	found := false
+	for _, schedule := range ab.Schedules {
+		t, ok, err := schedule.next(now, loc)
+		if err != nil {
+			return AudioBaitEvent{}, err
+		}
+		if ok && (!found || t.Before(next.Time)) {
+			found = true
+			next = AudioBaitEvent{
+				Schedule: schedule.Name,
+				Sound:    filepath.Join(ab.Dir, schedule.Sound),
+				Volume:   schedule.Volume,
+				Time:     t,
+			}
+		}
+	}
+	if !found {
+		return AudioBaitEvent{}, ErrNoAudioBaitEvent
+	}
+	return next, nil
+}
+
+func (s AudioBaitSchedule) next(now time.Time, loc Location) (time.Time, bool, error) {
+	start, err := parseScheduleTime(s.Start)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	stop, err := parseScheduleTime(s.Stop)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	// Start from yesterday's window as it might run over midnight.
+	for i := -1; i <= audioBaitLookAheadDays; i++ {
+		day := now.AddDate(0, 0, i)
+		windowStart, ok := start.on(day, loc)
+		if !ok {
+			continue
+		}
+		windowStop, ok := stop.on(day, loc)
+		if !ok {
+			continue
+		}
+		if !windowStop.After(windowStart) {
+			if windowStop, ok = stop.on(day.AddDate(0, 0, 1), loc); !ok {
+				continue
+			}
+		}
+		if t, ok := s.nextInWindow(now, windowStart, windowStop); ok {
+			return t, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+func (s AudioBaitSchedule) nextInWindow(now, windowStart, windowStop time.Time) (time.Time, bool) {
+	k := int64(0)
+	if s.Interval > 0 && now.After(windowStart.Add(s.Jitter)) {
+		k = int64(now.Sub(windowStart.Add(s.Jitter)) / s.Interval)
+	}
+	for ; ; k++ {
+		nominal := windowStart.Add(time.Duration(k) * s.Interval)
+		if !nominal.Before(windowStop) || (s.Interval == 0 && k > 0) {
+			return time.Time{}, false
+		}
+		t := nominal.Add(s.jitterAt(nominal))
+		if !t.Before(now) && t.Before(windowStop) {
+			return t, true
+		}
+	}
+}
+
+func (s AudioBaitSchedule) jitterAt(t time.Time) time.Duration {
+	if s.Jitter < time.Second {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(s.Name))
+	rng := splitmix64(h.Sum64() ^ uint64(t.Unix()))
+	return time.Duration(rng.next()%uint64(s.Jitter/time.Second)) * time.Second
+}
+
+// scheduleTime is a time of day, or an offset from sunrise or sunset.
+type scheduleTime struct {
+	ref    string
+	offset time.Duration
+}
+
+func parseScheduleTime(s string) (scheduleTime, error) {
+	for _, ref := range []string{SunriseTimeRef, SunsetTimeRef} {
+		rest, ok := strings.CutPrefix(s, ref)
+		if !ok {
+			continue
+		}
+		if rest == "" {
+			return scheduleTime{ref: ref}, nil
+		}
+		if rest[0] != '+' && rest[0] != '-' {
+			break
+		}
+		offset, err := time.ParseDuration(rest)
+		if err != nil {
+			return scheduleTime{}, fmt.Errorf("could not parse '%s' as a %s offset: %w", s, ref, err)
+		}
+		return scheduleTime{ref: ref, offset: offset}, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return scheduleTime{}, fmt.Errorf("could not parse '%s' as a time of day, sunrise or sunset", s)
+	}
+	return scheduleTime{offset: time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute}, nil
+}
+
+// on returns the time on the date of day. It returns false if it is relative
+// to a sunrise or sunset that doesn't happen that day.
+func (st scheduleTime) on(day time.Time, loc Location) (time.Time, bool) {
+	switch st.ref {
+	case SunriseTimeRef, SunsetTimeRef:
+		sunrise, sunset, ok := SunTimes(day, float64(loc.Latitude), float64(loc.Longitude))
+		if !ok {
+			return time.Time{}, false
+		}
+		if st.ref == SunriseTimeRef {
+			return sunrise.Add(st.offset), true
+		}
+		return sunset.Add(st.offset), true
+	default:
+		// Minutes past midnight are normalised by time.Date, which keeps
+		// the wall clock time on daylight saving days.
+		return time.Date(day.Year(), day.Month(), day.Day(), 0, int(st.offset/time.Minute), 0, 0, day.Location()), true
+	}
+}
+
+func validateAudioBaitSchedules(ab AudioBait) error {
+	names := map[string]struct{}{}
+	for _, s := range ab.Schedules {
+		if s.Name == "" {
+			return errors.New("audio bait schedule has no name")
+		}
+		if _, ok := names[s.Name]; ok {
+			return fmt.Errorf("audio bait schedule '%s' is defined more than once", s.Name)
+		}
+		names[s.Name] = struct{}{}
+
+		if s.Sound == "" || filepath.IsAbs(s.Sound) {
+			return fmt.Errorf("audio bait schedule '%s' sound must be a file in '%s'", s.Name, ab.Dir)
+		}
+		exists, err := afero.Exists(fs, filepath.Join(ab.Dir, s.Sound))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("audio bait schedule '%s' sound '%s' not found in '%s'", s.Name, s.Sound, ab.Dir)
+		}
+		if s.Volume < MinAudioBaitVolume || s.Volume > MaxAudioBaitVolume {
+			return fmt.Errorf("audio bait schedule '%s' volume must be between %d and %d, got %d",
+				s.Name, MinAudioBaitVolume, MaxAudioBaitVolume, s.Volume)
+		}
+		if _, err := parseScheduleTime(s.Start); err != nil {
+			return fmt.Errorf("audio bait schedule '%s' start: %w", s.Name, err)
+		}
+		if _, err := parseScheduleTime(s.Stop); err != nil {
+			return fmt.Errorf("audio bait schedule '%s' stop: %w", s.Name, err)
+		}
+		if s.Interval < 0 || s.Jitter < 0 {
+			return fmt.Errorf("audio bait schedule '%s' interval and jitter can't be negative", s.Name)
+		}
+		if s.Interval > 0 && s.Jitter >= s.Interval {
+			return fmt.Errorf("audio bait schedule '%s' jitter (%s) must be less than the interval (%s)",
+				s.Name, s.Jitter, s.Interval)
+		}
+	}
+	return nil
+}
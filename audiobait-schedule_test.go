@@ -0,0 +1,145 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func testAudioBait(t *testing.T) AudioBait {
+	ab := DefaultAudioBait()
+	ab.Schedules = []AudioBaitSchedule{
+		{Name: "morning", Sound: "possum.wav", Volume: 5, Start: "06:00", Stop: "08:00", Interval: 30 * time.Minute},
+		{Name: "dusk", Sound: "rat.wav", Volume: 8, Start: "sunset-30m", Stop: "sunrise", Interval: 2 * time.Hour, Jitter: 10 * time.Minute},
+	}
+	for _, s := range ab.Schedules {
+		require.NoError(t, afero.WriteFile(fs, filepath.Join(ab.Dir, s.Sound), []byte("RIFF"), 0644))
+	}
+	return ab
+}
+
+func TestAudioBaitValidation(t *testing.T) {
+	defer newFs(t, "")()
+	require.NoError(t, audioBaitValidateFunc(testAudioBait(t)))
+
+	for name, modify := range map[string]func(s *AudioBaitSchedule){
+		"no name":         func(s *AudioBaitSchedule) { s.Name = "" },
+		"duplicate":       func(s *AudioBaitSchedule) { s.Name = "dusk" },
+		"missing sound":   func(s *AudioBaitSchedule) { s.Sound = "cat.wav" },
+		"absolute sound":  func(s *AudioBaitSchedule) { s.Sound = "/var/lib/audiobait/possum.wav" },
+		"volume":          func(s *AudioBaitSchedule) { s.Volume = 11 },
+		"start":           func(s *AudioBaitSchedule) { s.Start = "25:00" },
+		"stop":            func(s *AudioBaitSchedule) { s.Stop = "sunset+soon" },
+		"negative jitter": func(s *AudioBaitSchedule) { s.Jitter = -time.Minute },
+		"jitter":          func(s *AudioBaitSchedule) { s.Jitter = time.Hour },
+	} {
+		ab := testAudioBait(t)
+		modify(&ab.Schedules[0])
+		require.Error(t, audioBaitValidateFunc(ab), name)
+	}
+
+	require.Error(t, audioBaitValidateFunc(map[string]any{
+		"schedules": []map[string]any{{"name": "a", "sound": "cat.wav", "volume": 5, "start": "06:00", "stop": "07:00"}},
+	}))
+}
+
+func TestAudioBaitNextEvent(t *testing.T) {
+	nz, err := time.LoadLocation("Pacific/Auckland")
+	require.NoError(t, err)
+	loc := DefaultWindowLocation()
+	ab := AudioBait{
+		Dir: "/var/lib/audiobait",
+		Schedules: []AudioBaitSchedule{
+			{Name: "morning", Sound: "possum.wav", Volume: 5, Start: "06:00", Stop: "08:00", Interval: 30 * time.Minute},
+		},
+	}
+
+	event, err := ab.NextEvent(time.Date(2024, 1, 1, 6, 10, 0, 0, nz), loc)
+	require.NoError(t, err)
+	require.Equal(t, AudioBaitEvent{
+		Schedule: "morning",
+		Sound:    "/var/lib/audiobait/possum.wav",
+		Volume:   5,
+		Time:     time.Date(2024, 1, 1, 6, 30, 0, 0, nz),
+	}, event)
+
+	// The last play is before the stop time, so the next is tomorrow.
+	event, err = ab.NextEvent(time.Date(2024, 1, 1, 7, 31, 0, 0, nz), loc)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 1, 2, 6, 0, 0, 0, nz), event.Time)
+
+	// Sunset is at about 21:13, and the window runs over midnight to sunrise.
+	ab.Schedules = append(ab.Schedules, AudioBaitSchedule{
+		Name: "dusk", Sound: "rat.wav", Volume: 8, Start: "sunset-30m", Stop: "sunrise", Interval: 2 * time.Hour,
+	})
+	event, err = ab.NextEvent(time.Date(2024, 1, 1, 12, 0, 0, 0, nz), loc)
+	require.NoError(t, err)
+	require.Equal(t, "dusk", event.Schedule)
+	require.WithinDuration(t, time.Date(2024, 1, 1, 20, 43, 0, 0, nz), event.Time, 2*time.Minute)
+
+	event, err = ab.NextEvent(time.Date(2024, 1, 2, 1, 0, 0, 0, nz), loc)
+	require.NoError(t, err)
+	require.Equal(t, "dusk", event.Schedule)
+	require.WithinDuration(t, time.Date(2024, 1, 2, 2, 43, 0, 0, nz), event.Time, 2*time.Minute)
+
+	_, err = AudioBait{}.NextEvent(time.Now(), loc)
+	require.ErrorIs(t, err, ErrNoAudioBaitEvent)
+}
+
+func TestAudioBaitJitter(t *testing.T) {
+	s := AudioBaitSchedule{
+		Name: "jitter", Sound: "possum.wav", Volume: 5, Start: "00:00", Stop: "00:00",
+		Interval: time.Hour, Jitter: 15 * time.Minute,
+	}
+	ab := AudioBait{Schedules: []AudioBaitSchedule{s}}
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	jittered := false
+	for hour := 0; hour < 24; hour++ {
+		nominal := start.Add(time.Duration(hour) * time.Hour)
+		event, err := ab.NextEvent(nominal, Location{})
+		require.NoError(t, err)
+		require.False(t, event.Time.Before(nominal))
+		require.Less(t, event.Time.Sub(nominal), s.Jitter)
+		jittered = jittered || event.Time.After(nominal)
+
+		again, err := ab.NextEvent(nominal, Location{})
+		require.NoError(t, err)
+		require.Equal(t, event, again)
+	}
+	require.True(t, jittered)
+}
+
+func TestWritingAudioBaitSchedules(t *testing.T) {
+	defer newFs(t, "")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	ab := testAudioBait(t)
+	require.NoError(t, conf.Set(AudioBaitKey, ab))
+
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	var ab2 AudioBait
+	require.NoError(t, conf.Unmarshal(AudioBaitKey, &ab2))
+	require.Equal(t, ab, ab2)
+}
+
+func TestSetAudioBaitSchedulesInDirectory(t *testing.T) {
+	defer newFs(t, "")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	require.NoError(t, afero.WriteFile(fs, "/data/sounds/possum.wav", []byte("RIFF"), 0644))
+	schedules := map[string]any{
+		"schedules": []map[string]any{
+			{"name": "morning", "sound": "possum.wav", "volume": 5, "start": "06:00", "stop": "08:00", "interval": "30m"},
+		},
+	}
+
+	// The sounds are checked for in the directory in the config.
+	require.Error(t, conf.SetFromMap(AudioBaitKey, schedules, false))
+	require.NoError(t, conf.SetFromMap(AudioBaitKey, map[string]any{"directory": "/data/sounds"}, false))
+	require.NoError(t, conf.SetFromMap(AudioBaitKey, schedules, false))
+}
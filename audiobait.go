@@ -16,13 +16,19 @@
 
 package config
 
+import (
+	"reflect"
+
+	"github.com/mitchellh/mapstructure"
+)
+
 const AudioBaitKey = "audio-bait"
 
 func init() {
 	allSections[AudioBaitKey] = section{
 		key:         AudioBaitKey,
 		mapToStruct: audioBaitMapToStruct,
-		validate:    noValidateFunc,
+		validate:    audioBaitValidateFunc,
 		defaultValue: func() interface{} {
 			return DefaultAudioBait()
 		},
@@ -30,12 +36,14 @@ func init() {
 			return &AudioBait{}
 		},
 	}
+	allSectionDecodeHookFuncs = append(allSectionDecodeHookFuncs, audioBaitToMap)
 }
 
 type AudioBait struct {
-	Dir           string `mapstructure:"directory"`
-	Card          int    `mapstructure:"card"`
-	VolumeControl string `mapstructure:"volume-control"`
+	Dir           string              `mapstructure:"directory"`
+	Card          int                 `mapstructure:"card"`
+	VolumeControl string              `mapstructure:"volume-control"`
+	Schedules     []AudioBaitSchedule `mapstructure:"schedules"`
 }
 
 func DefaultAudioBait() AudioBait {
@@ -53,3 +61,40 @@ func audioBaitMapToStruct(m map[string]interface{}) (interface{}, error) {
 	}
 	return s, nil
 }
+
+func audioBaitValidateFunc(audioBait any) error {
+	ab, err := sectionStruct[AudioBait](AudioBaitKey, audioBait)
+	if err != nil {
+		return err
+	}
+	return validateAudioBaitSchedules(ab)
+}
+
+func audioBaitToMap(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if t != mapStrInterfaceType {
+		return data, nil
+	}
+	switch f {
+	case reflect.TypeOf(&AudioBait{}):
+		data = *(data.(*AudioBait)) // follow the pointer
+		fallthrough
+	case reflect.TypeOf(AudioBait{}):
+		m := map[string]interface{}{}
+		if err := mapstructure.Decode(data, &m); err != nil {
+			return nil, err
+		}
+		// Sections without schedules are written as they were before.
+		delete(m, "schedules")
+		if len(data.(AudioBait).Schedules) == 0 {
+			return m, nil
+		}
+		schedules := []map[string]interface{}{}
+		if err := mapstructure.Decode(data.(AudioBait).Schedules, &schedules); err != nil {
+			return nil, err
+		}
+		m["schedules"] = schedules
+		return m, nil
+	default:
+		return data, nil
+	}
+}
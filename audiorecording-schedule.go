@@ -0,0 +1,91 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	AudioModeDisabled        = "Disabled"
+	AudioModeAudioOnly       = "AudioOnly"
+	AudioModeAudioAndThermal = "AudioAndThermal"
+	AudioModeAudioOrThermal  = "AudioOrThermal"
+)
+
+const (
+	// AudioScheduleSlot is the length of the slots a day is split into, with one recording in each
+	AudioScheduleSlot = 30 * time.Minute
+	// AudioRecordingLength is how long each audio recording is
+	AudioRecordingLength = time.Minute
+)
+
+// Schedule returns the start times of the audio recordings on the day that
+// day falls on in loc, or nil if audio recording is disabled.
+//
+// The day, from local midnight to the next local midnight, is split into
+// AudioScheduleSlot slots from midnight, the last one may be shorter on a
+// daylight saving change. Each slot has one recording starting at a random
+// whole second, chosen so that the recording ends within the slot. The random
+// numbers come from splitmix64 seeded with the AudioSeed in the upper 32 bits
+// and the days since 1970-01-01 of the local date in the lower 32 bits, taking
+// each start as next() % (slot length in seconds - AudioRecordingLength in
+// seconds + 1). This is simple enough for the server and other tools to
+// reproduce.
+func (ar AudioRecording) Schedule(day time.Time, loc *time.Location) ([]time.Time, error) {
+	switch ar.AudioMode {
+	case AudioModeDisabled, "":
+		return nil, nil
+	case AudioModeAudioOnly, AudioModeAudioAndThermal, AudioModeAudioOrThermal:
+	default:
+		return nil, fmt.Errorf("unknown audio mode '%s'", ar.AudioMode)
+	}
+	if loc == nil {
+		loc = day.Location()
+	}
+
+	day = day.In(loc)
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+	end := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+	epochDay := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+	rng := splitmix64(uint64(ar.AudioSeed)<<32 | uint64(uint32(epochDay)))
+
+	starts := []time.Time{}
+	for slot := start; slot.Before(end); slot = slot.Add(AudioScheduleSlot) {
+		slotSecs := int64(min(AudioScheduleSlot, end.Sub(slot)) / time.Second)
+		choices := slotSecs - int64(AudioRecordingLength/time.Second) + 1
+		if choices <= 0 {
+			continue
+		}
+		offset := int64(rng.next() % uint64(choices))
+		starts = append(starts, slot.Add(time.Duration(offset)*time.Second))
+	}
+	return starts, nil
+}
+
+// splitmix64 is a small, well known pseudo random number generator, used so
+// schedules can be reproduced outside of Go.
+type splitmix64 uint64
+
+func (s *splitmix64) next() uint64 {
+	*s += 0x9e3779b97f4a7c15
+	z := uint64(*s)
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudioRecordingSchedule(t *testing.T) {
+	nz, err := time.LoadLocation("Pacific/Auckland")
+	require.NoError(t, err)
+	ar := AudioRecording{AudioMode: AudioModeAudioOnly, AudioSeed: 1234}
+	day := time.Date(2024, 3, 1, 15, 4, 5, 0, nz)
+
+	starts, err := ar.Schedule(day, nz)
+	require.NoError(t, err)
+	require.Len(t, starts, 48)
+	midnight := time.Date(2024, 3, 1, 0, 0, 0, 0, nz)
+	for i, s := range starts {
+		slot := midnight.Add(time.Duration(i) * AudioScheduleSlot)
+		require.False(t, s.Before(slot), i)
+		require.False(t, s.Add(AudioRecordingLength).After(slot.Add(AudioScheduleSlot)), i)
+		require.Zero(t, s.Nanosecond())
+	}
+
+	// The same for any time on the day, and from any location.
+	again, err := ar.Schedule(day.UTC(), nz)
+	require.NoError(t, err)
+	require.Equal(t, starts, again)
+
+	// Pins the algorithm so other implementations can be checked against it.
+	require.Equal(t, time.Date(2024, 3, 1, 0, 20, 26, 0, nz), starts[0])
+
+	other, err := AudioRecording{AudioMode: AudioModeAudioOnly, AudioSeed: 1235}.Schedule(day, nz)
+	require.NoError(t, err)
+	require.NotEqual(t, starts, other)
+	nextDay, err := ar.Schedule(day.AddDate(0, 0, 1), nz)
+	require.NoError(t, err)
+	require.NotEqual(t, starts[0].Sub(midnight), nextDay[0].Sub(midnight.AddDate(0, 0, 1)))
+}
+
+func TestAudioRecordingScheduleDaylightSaving(t *testing.T) {
+	nz, err := time.LoadLocation("Pacific/Auckland")
+	require.NoError(t, err)
+	ar := AudioRecording{AudioMode: AudioModeAudioAndThermal, AudioSeed: 1}
+
+	// 25 hour day
+	starts, err := ar.Schedule(time.Date(2024, 4, 7, 12, 0, 0, 0, nz), nz)
+	require.NoError(t, err)
+	require.Len(t, starts, 50)
+
+	// 23 hour day
+	starts, err = ar.Schedule(time.Date(2024, 9, 29, 12, 0, 0, 0, nz), nz)
+	require.NoError(t, err)
+	require.Len(t, starts, 46)
+}
+
+func TestAudioRecordingScheduleModes(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	starts, err := DefaultAudioRecording().Schedule(day, nil)
+	require.NoError(t, err)
+	require.Nil(t, starts)
+
+	_, err = AudioRecording{AudioMode: "Sometimes"}.Schedule(day, nil)
+	require.Error(t, err)
+}
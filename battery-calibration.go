@@ -0,0 +1,227 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MinCalibrationPoints is the minimum number of points in a calibrated discharge curve
+const MinCalibrationPoints = 11
+
+// DischargeSample is a single reading from a discharge log
+type DischargeSample struct {
+	Time       time.Time
+	Voltage    float32 // Pack voltage
+	Current    float32 // Amps, positive when discharging
+	HasCurrent bool
+}
+
+// ParseDischargeLog reads a CSV discharge log with rows of timestamp, voltage
+// and an optional current. Timestamps can be RFC3339 or unix seconds. A header
+// row is skipped if present.
+func ParseDischargeLog(r io.Reader) ([]DischargeSample, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	samples := []DischargeSample{}
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 || len(record) > 3 {
+			return nil, fmt.Errorf("row %d: expected timestamp, voltage and optional current, got %d fields", row, len(record))
+		}
+
+		t, err := parseDischargeTimestamp(record[0])
+		if err != nil {
+			if row == 1 {
+				continue // Header row
+			}
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		voltage, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 32)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid voltage '%s'", row, record[1])
+		}
+		sample := DischargeSample{Time: t, Voltage: float32(voltage)}
+		if len(record) == 3 && strings.TrimSpace(record[2]) != "" {
+			current, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 32)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: invalid current '%s'", row, record[2])
+			}
+			sample.Current = float32(current)
+			sample.HasCurrent = true
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+func parseDischargeTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	secs, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp '%s'", s)
+	}
+	return time.Unix(0, int64(secs*float64(time.Second))), nil
+}
+
+// CalibrateDischargeCurve fits a single-cell discharge curve with the given
+// number of evenly spaced points to a log of a full to empty discharge of a
+// pack with cellCount cells in series.
+//
+// If every sample has a current the state of charge of each sample is found
+// from the charge drawn, otherwise the load is assumed to be constant and time
+// is used instead. The voltage at each point is taken from a straight line
+// fitted to the samples within half a step of it, which averages out noise
+// without pulling the end points towards the middle of the curve.
+func CalibrateDischargeCurve(samples []DischargeSample, cellCount, points int) (BatteryType, error) {
+	if cellCount < 1 || cellCount > 24 {
+		return BatteryType{}, fmt.Errorf("cell count must be between 1 and 24, got %d", cellCount)
+	}
+	if points < MinCalibrationPoints {
+		return BatteryType{}, fmt.Errorf("curve needs at least %d points, got %d", MinCalibrationPoints, points)
+	}
+	if len(samples) < points {
+		return BatteryType{}, fmt.Errorf("need at least %d samples, got %d", points, len(samples))
+	}
+
+	samples = append([]DischargeSample{}, samples...)
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].Time.Before(samples[j].Time)
+	})
+	first, last := samples[0], samples[len(samples)-1]
+	if !last.Time.After(first.Time) {
+		return BatteryType{}, fmt.Errorf("discharge log covers no time")
+	}
+	if last.Voltage >= first.Voltage {
+		return BatteryType{}, fmt.Errorf("discharge log doesn't go from full to empty (%.2fV to %.2fV)", first.Voltage, last.Voltage)
+	}
+
+	soc, err := dischargeStateOfCharge(samples)
+	if err != nil {
+		return BatteryType{}, err
+	}
+
+	halfStep := 50 / float64(points-1)
+	curve := BatteryType{
+		Chemistry: ChemistryCustom,
+		Voltages:  make([]float32, points),
+		Percent:   make([]float32, points),
+	}
+	for k := range points {
+		percent := 100 * float64(k) / float64(points-1)
+		var xs, ys []float64
+		nearest, nearestDist := 0, math.Inf(1)
+		for i, s := range soc {
+			dist := math.Abs(s - percent)
+			if dist <= halfStep {
+				xs = append(xs, s)
+				ys = append(ys, float64(samples[i].Voltage))
+			}
+			if dist < nearestDist {
+				nearest, nearestDist = i, dist
+			}
+		}
+		voltage, ok := linearFitAt(xs, ys, percent)
+		if !ok {
+			voltage = float64(samples[nearest].Voltage)
+		}
+		curve.Percent[k] = float32(percent)
+		curve.Voltages[k] = float32(voltage / float64(cellCount))
+	}
+
+	// Noise can leave the curve decreasing in places, which the interpolation can't handle.
+	for k := 1; k < points; k++ {
+		if curve.Voltages[k] < curve.Voltages[k-1] {
+			curve.Voltages[k] = curve.Voltages[k-1]
+		}
+	}
+	curve.MinVoltage = curve.Voltages[0]
+	curve.MaxVoltage = curve.Voltages[points-1]
+	return curve, nil
+}
+
+// dischargeStateOfCharge returns the state of charge of each sample, samples must be sorted by time.
+func dischargeStateOfCharge(samples []DischargeSample) ([]float64, error) {
+	hasCurrent := true
+	for _, s := range samples {
+		hasCurrent = hasCurrent && s.HasCurrent
+	}
+
+	soc := make([]float64, len(samples))
+	if !hasCurrent {
+		start, end := samples[0].Time, samples[len(samples)-1].Time
+		for i, s := range samples {
+			soc[i] = 100 * (1 - s.Time.Sub(start).Hours()/end.Sub(start).Hours())
+		}
+		return soc, nil
+	}
+
+	drawn := make([]float64, len(samples))
+	for i := 1; i < len(samples); i++ {
+		hours := samples[i].Time.Sub(samples[i-1].Time).Hours()
+		drawn[i] = drawn[i-1] + float64(samples[i-1].Current+samples[i].Current)/2*hours
+	}
+	total := drawn[len(drawn)-1]
+	if total <= 0 {
+		return nil, fmt.Errorf("no charge was drawn in the discharge log")
+	}
+	for i := range samples {
+		soc[i] = 100 * (1 - drawn[i]/total)
+	}
+	return soc, nil
+}
+
+// linearFitAt fits a least squares line to the points and returns its value at x.
+func linearFitAt(xs, ys []float64, x float64) (float64, bool) {
+	n := float64(len(xs))
+	if n < 2 {
+		return 0, false
+	}
+	var sumX, sumY, sumXX, sumXY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXX += xs[i] * xs[i]
+		sumXY += xs[i] * ys[i]
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0, false
+	}
+	slope := (n*sumXY - sumX*sumY) / denom
+	intercept := (sumY - slope*sumX) / n
+	return intercept + slope*x, true
+}
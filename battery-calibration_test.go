@@ -0,0 +1,156 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// dischargeLog simulates a full to empty discharge of a 3 cell pack following
+// the LiIon curve, with a higher load during the second half.
+func dischargeLog(withCurrent bool) string {
+	var sb strings.Builder
+	sb.WriteString("timestamp,voltage,current\n")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t := start
+	for percent := 100.0; percent >= 0; percent -= 0.5 {
+		voltage := 3 * curveCellVoltage(LiIonChemistry, float32(percent))
+		current := 1.0
+		if percent < 50 {
+			current = 2.0
+		}
+		if withCurrent {
+			fmt.Fprintf(&sb, "%s,%.3f,%.1f\n", t.Format(time.RFC3339), voltage, current)
+		} else {
+			fmt.Fprintf(&sb, "%d,%.3f\n", t.Unix(), voltage)
+		}
+		// 0.5% of a 10Ah pack
+		t = t.Add(time.Duration(0.05 / current * float64(time.Hour)))
+	}
+	return sb.String()
+}
+
+// curveCellVoltage returns the cell voltage for the given percent on the curve.
+func curveCellVoltage(bt BatteryType, percent float32) float32 {
+	for i := 1; i < len(bt.Percent); i++ {
+		if percent <= bt.Percent[i] {
+			frac := (percent - bt.Percent[i-1]) / (bt.Percent[i] - bt.Percent[i-1])
+			return bt.Voltages[i-1] + frac*(bt.Voltages[i]-bt.Voltages[i-1])
+		}
+	}
+	return bt.Voltages[len(bt.Voltages)-1]
+}
+
+func TestParseDischargeLog(t *testing.T) {
+	log := "timestamp,voltage,current\n" +
+		"2024-01-01T00:00:00Z,12.5,0.5\n" +
+		"# a comment\n" +
+		"1704067260, 12.4\n"
+	samples, err := ParseDischargeLog(strings.NewReader(log))
+	require.NoError(t, err)
+	require.Equal(t, []DischargeSample{
+		{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Voltage: 12.5, Current: 0.5, HasCurrent: true},
+		{Time: time.Unix(1704067260, 0), Voltage: 12.4},
+	}, samples)
+
+	_, err = ParseDischargeLog(strings.NewReader("2024-01-01T00:00:00Z,12.5\nnot a time,12.4\n"))
+	require.Error(t, err)
+	_, err = ParseDischargeLog(strings.NewReader("2024-01-01T00:00:00Z,twelve\n"))
+	require.Error(t, err)
+	_, err = ParseDischargeLog(strings.NewReader("2024-01-01T00:00:00Z\n"))
+	require.Error(t, err)
+}
+
+func TestCalibrateDischargeCurve(t *testing.T) {
+	for _, withCurrent := range []bool{true, false} {
+		t.Run(fmt.Sprintf("with current %v", withCurrent), func(t *testing.T) {
+			samples, err := ParseDischargeLog(strings.NewReader(dischargeLog(withCurrent)))
+			require.NoError(t, err)
+
+			curve, err := CalibrateDischargeCurve(samples, 3, 11)
+			require.NoError(t, err)
+			require.Equal(t, ChemistryCustom, curve.Chemistry)
+			require.Len(t, curve.Voltages, 11)
+			require.Equal(t, LiIonChemistry.Percent, curve.Percent)
+			require.Equal(t, curve.Voltages[0], curve.MinVoltage)
+			require.Equal(t, curve.Voltages[10], curve.MaxVoltage)
+
+			// The load changes half way through, so only the charge drawn gives the real
+			// curve. Some smoothing of the sharp corners in the generic curve is expected.
+			tolerance := 0.05
+			if !withCurrent {
+				tolerance = 0.4
+			}
+			for i := range curve.Voltages {
+				require.InDelta(t, LiIonChemistry.Voltages[i], curve.Voltages[i], tolerance, "point %d", i)
+			}
+		})
+	}
+}
+
+func TestCalibrateDischargeCurveMorePoints(t *testing.T) {
+	samples, err := ParseDischargeLog(strings.NewReader(dischargeLog(true)))
+	require.NoError(t, err)
+	curve, err := CalibrateDischargeCurve(samples, 3, 21)
+	require.NoError(t, err)
+	require.Len(t, curve.Voltages, 21)
+	require.NoError(t, validateDischargeCurve(curve.Voltages, curve.Percent))
+}
+
+func TestCalibrateDischargeCurveErrors(t *testing.T) {
+	samples, err := ParseDischargeLog(strings.NewReader(dischargeLog(true)))
+	require.NoError(t, err)
+
+	_, err = CalibrateDischargeCurve(samples, 3, 5)
+	require.Error(t, err)
+	_, err = CalibrateDischargeCurve(samples, 0, 11)
+	require.Error(t, err)
+	_, err = CalibrateDischargeCurve(samples[:5], 3, 11)
+	require.Error(t, err)
+
+	// Charging log
+	reversed := make([]DischargeSample, len(samples))
+	for i, s := range samples {
+		reversed[i] = s
+		reversed[i].Voltage = samples[len(samples)-1-i].Voltage
+	}
+	_, err = CalibrateDischargeCurve(reversed, 3, 11)
+	require.Error(t, err)
+}
+
+func TestCustomBatteryProfile(t *testing.T) {
+	defer newFs(t, "")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	samples, err := ParseDischargeLog(strings.NewReader(dischargeLog(true)))
+	require.NoError(t, err)
+	curve, err := CalibrateDischargeCurve(samples, 3, 11)
+	require.NoError(t, err)
+
+	b := DefaultBattery()
+	require.NoError(t, b.SetCustomProfile(curve))
+	require.NoError(t, conf.Set(BatteryKey, b))
+
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	var b2 Battery
+	require.NoError(t, conf.Unmarshal(BatteryKey, &b2))
+	require.Equal(t, ChemistryCustom, b2.Chemistry)
+	require.True(t, b2.ManuallyConfigured)
+	require.Equal(t, curve, b2.CustomProfile())
+
+	pack, err := b2.GetBatteryPack(11.1)
+	require.NoError(t, err)
+	require.Equal(t, 3, pack.CellCount)
+	percent, err := pack.VoltageToPercent(11.1)
+	require.NoError(t, err)
+	require.InDelta(t, 60, percent, 1)
+
+	// Custom chemistry without a curve isn't valid.
+	require.Error(t, batteryValidateFunc(map[string]any{"chemistry": "custom"}))
+	require.Error(t, batteryValidateFunc(map[string]any{"custom-voltages": []float32{3, 2}, "custom-percent": []float32{0, 100}}))
+}
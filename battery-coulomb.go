@@ -0,0 +1,228 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	DefaultCoulombCounterStateFile = "/var/lib/cacophony/battery-soc.json"
+
+	// Default tuning for the CoulombCounter
+	DefaultRestCurrent       = 0.05 // Amps, below this the battery is considered to be at rest
+	DefaultRestDuration      = 30 * time.Minute
+	DefaultStateSaveInterval = 10 * time.Minute
+
+	// Re-anchoring points must be at least this far apart before they are used to estimate capacity.
+	minCapacitySpanPercent = 30
+	// How much a new capacity measurement moves the capacity estimate.
+	capacitySmoothing = 0.2
+	// The capacity estimate is kept within these fractions of the rated capacity.
+	minCapacityFraction = 0.5
+	maxCapacityFraction = 1.1
+	// At full charge the charge current tapers below this fraction of the capacity (C/20).
+	fullChargeTailFraction = 0.05
+)
+
+// CoulombCounterState is the part of the CoulombCounter that is saved so it survives reboots.
+type CoulombCounterState struct {
+	Percent         float64   `json:"percent"`           // Current state of charge, -1 if unknown
+	RatedCapacityAh float64   `json:"rated-capacity-ah"` // Capacity the pack was configured with
+	CapacityAh      float64   `json:"capacity-ah"`       // Estimated capacity after fade
+	AnchorPercent   float64   `json:"anchor-percent"`    // Voltage based state of charge at the last re-anchor, -1 if none
+	AnchorChargeAh  float64   `json:"anchor-charge-ah"`  // Charge drawn since the last re-anchor, positive when discharging
+	Updated         time.Time `json:"updated"`
+}
+
+// CoulombCounter estimates the state of charge by integrating the current
+// drawn from the battery. Voltage based state of charge is too flat to be
+// useful for some chemistries, so it is only used to re-anchor the count when
+// the battery has been at rest for a while or has reached full charge. The
+// charge counted between two re-anchors is used to track capacity fade.
+type CoulombCounter struct {
+	RestCurrent       float32       // Amps, below this the battery is considered to be at rest
+	RestDuration      time.Duration // How long the battery needs to be at rest before re-anchoring
+	StateSaveInterval time.Duration // How often the state is saved, 0 to only save when calling Save
+
+	pack      *BatteryPack
+	statePath string
+	state     CoulombCounterState
+	lastTime  time.Time
+	restSince time.Time
+	lastSave  time.Time
+}
+
+// NewCoulombCounter creates a CoulombCounter for the pack with the given rated
+// capacity. If statePath holds state saved for a pack of the same rated
+// capacity it is loaded, otherwise the count starts from the first reading.
+func NewCoulombCounter(pack *BatteryPack, ratedCapacityAh float64, statePath string) (*CoulombCounter, error) {
+	if pack == nil || pack.Type == nil || pack.CellCount <= 0 {
+		return nil, fmt.Errorf("no battery pack defined")
+	}
+	if ratedCapacityAh <= 0 {
+		return nil, fmt.Errorf("rated capacity must be > 0, got %v", ratedCapacityAh)
+	}
+	cc := &CoulombCounter{
+		RestCurrent:       DefaultRestCurrent,
+		RestDuration:      DefaultRestDuration,
+		StateSaveInterval: DefaultStateSaveInterval,
+		pack:              pack,
+		statePath:         statePath,
+		state: CoulombCounterState{
+			Percent:         -1,
+			RatedCapacityAh: ratedCapacityAh,
+			CapacityAh:      ratedCapacityAh,
+			AnchorPercent:   -1,
+		},
+	}
+
+	state, err := loadCoulombCounterState(statePath)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil && state.RatedCapacityAh == ratedCapacityAh {
+		cc.state = *state
+	}
+	return cc, nil
+}
+
+func loadCoulombCounterState(statePath string) (*CoulombCounterState, error) {
+	if statePath == "" {
+		return nil, nil
+	}
+	b, err := afero.ReadFile(fs, statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	state := &CoulombCounterState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("failed to parse battery state file %s: %w", statePath, err)
+	}
+	return state, nil
+}
+
+// Update adds a voltage and current reading taken at the given time. Current
+// is positive when discharging and negative when charging.
+func (cc *CoulombCounter) Update(t time.Time, voltage, currentA float32) error {
+	// Current is integrated from the previous reading since starting, so the time
+	// over a reboot isn't counted as what happened in between is unknown.
+	if !cc.lastTime.IsZero() && t.After(cc.lastTime) {
+		chargeAh := float64(currentA) * t.Sub(cc.lastTime).Hours()
+		cc.state.AnchorChargeAh += chargeAh
+		if cc.state.Percent >= 0 {
+			cc.state.Percent -= chargeAh / cc.state.CapacityAh * 100
+			cc.state.Percent = math.Min(math.Max(cc.state.Percent, 0), 100)
+		}
+	}
+
+	if float32(math.Abs(float64(currentA))) > cc.RestCurrent {
+		cc.restSince = time.Time{}
+	} else if cc.restSince.IsZero() {
+		cc.restSince = t
+	}
+
+	switch {
+	case cc.isFull(voltage, currentA):
+		cc.anchor(100)
+	case cc.state.Percent < 0, !cc.restSince.IsZero() && t.Sub(cc.restSince) >= cc.RestDuration:
+		percent, err := cc.pack.VoltageToPercentCompensated(voltage, float32(math.NaN()), currentA)
+		if err != nil {
+			return err
+		}
+		cc.anchor(float64(percent))
+		// Wait for another full rest period before re-anchoring again.
+		cc.restSince = time.Time{}
+	}
+
+	cc.lastTime = t
+	cc.state.Updated = t
+	if cc.StateSaveInterval > 0 && t.Sub(cc.lastSave) >= cc.StateSaveInterval {
+		if err := cc.Save(); err != nil {
+			return err
+		}
+		cc.lastSave = t
+	}
+	return nil
+}
+
+// isFull returns true if the pack is at its maximum voltage and the charge current has tapered off.
+func (cc *CoulombCounter) isFull(voltage, currentA float32) bool {
+	tail := fullChargeTailFraction * cc.state.CapacityAh
+	return voltage >= cc.pack.GetScaledMaxVoltage() && currentA <= 0 && float64(-currentA) <= tail
+}
+
+// anchor resets the state of charge to a voltage based value and, if enough
+// charge has been counted since the previous anchor, updates the capacity estimate.
+func (cc *CoulombCounter) anchor(percent float64) {
+	if cc.state.AnchorPercent >= 0 {
+		span := cc.state.AnchorPercent - percent
+		// Only use the measurement if the charge and voltage agree on the direction.
+		if math.Abs(span) >= minCapacitySpanPercent && span*cc.state.AnchorChargeAh > 0 {
+			measured := cc.state.AnchorChargeAh / (span / 100)
+			capacity := (1-capacitySmoothing)*cc.state.CapacityAh + capacitySmoothing*measured
+			capacity = math.Max(capacity, minCapacityFraction*cc.state.RatedCapacityAh)
+			capacity = math.Min(capacity, maxCapacityFraction*cc.state.RatedCapacityAh)
+			cc.state.CapacityAh = capacity
+		}
+	}
+	cc.state.Percent = percent
+	cc.state.AnchorPercent = percent
+	cc.state.AnchorChargeAh = 0
+}
+
+// Percent returns the estimated state of charge, -1 if there have been no readings yet.
+func (cc *CoulombCounter) Percent() float32 {
+	return float32(cc.state.Percent)
+}
+
+// CapacityAh returns the estimated capacity of the pack.
+func (cc *CoulombCounter) CapacityAh() float64 {
+	return cc.state.CapacityAh
+}
+
+// CapacityFade returns the fraction of the rated capacity that has been lost.
+func (cc *CoulombCounter) CapacityFade() float64 {
+	return 1 - cc.state.CapacityAh/cc.state.RatedCapacityAh
+}
+
+// State returns a copy of the current state.
+func (cc *CoulombCounter) State() CoulombCounterState {
+	return cc.state
+}
+
+// Save writes the state to the state file. The file is written to a temporary
+// file first so a power cut while writing won't corrupt the previous state.
+func (cc *CoulombCounter) Save() error {
+	if cc.statePath == "" {
+		return nil
+	}
+	b, err := json.Marshal(cc.state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(cc.statePath, b, 0644)
+}
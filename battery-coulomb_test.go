@@ -0,0 +1,132 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// 4 cell LiIon pack voltages, resting voltage at 50% and full charge.
+const (
+	liIonPack50   = 14.4
+	liIonPack20   = 12.8
+	liIonPackFull = 16.8
+)
+
+func newTestCoulombCounter(t *testing.T, capacityAh float64) *CoulombCounter {
+	pack := &BatteryPack{Type: &LiIonChemistry, CellCount: 4}
+	cc, err := NewCoulombCounter(pack, capacityAh, DefaultCoulombCounterStateFile)
+	require.NoError(t, err)
+	return cc
+}
+
+func TestCoulombCounterIntegratesCurrent(t *testing.T) {
+	defer newFs(t, "")()
+	cc := newTestCoulombCounter(t, 10)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// First reading anchors to the voltage based state of charge.
+	require.NoError(t, cc.Update(start, liIonPack50, 0))
+	require.InDelta(t, 50, cc.Percent(), 0.1)
+
+	// 1A for 2 hours from a 10Ah pack is 20%, the voltage sagging under
+	// load is ignored as the battery isn't at rest.
+	for m := 1; m <= 120; m++ {
+		require.NoError(t, cc.Update(start.Add(time.Duration(m)*time.Minute), 13.0, 1))
+	}
+	require.InDelta(t, 30, cc.Percent(), 0.1)
+
+	// Charging 0.5A for an hour.
+	for m := 121; m <= 180; m++ {
+		require.NoError(t, cc.Update(start.Add(time.Duration(m)*time.Minute), 14.0, -0.5))
+	}
+	require.InDelta(t, 35, cc.Percent(), 0.1)
+}
+
+func TestCoulombCounterReanchorsAtRest(t *testing.T) {
+	defer newFs(t, "")()
+	cc := newTestCoulombCounter(t, 10)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, cc.Update(start, liIonPack50, 0))
+
+	// A small drift from the current sensor offset.
+	for m := 1; m <= 20; m++ {
+		require.NoError(t, cc.Update(start.Add(time.Duration(m)*time.Minute), liIonPack50, 0.04))
+	}
+	require.Less(t, cc.Percent(), float32(50))
+
+	for m := 21; m <= 60; m++ {
+		require.NoError(t, cc.Update(start.Add(time.Duration(m)*time.Minute), liIonPack50, 0))
+	}
+	require.InDelta(t, 50, cc.Percent(), 0.1)
+}
+
+func TestCoulombCounterReanchorsAtFullCharge(t *testing.T) {
+	defer newFs(t, "")()
+	cc := newTestCoulombCounter(t, 10)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, cc.Update(start, liIonPack50, 0))
+
+	// Still charging hard at max voltage isn't full yet.
+	require.NoError(t, cc.Update(start.Add(time.Minute), liIonPackFull, -2))
+	require.Less(t, cc.Percent(), float32(100))
+
+	require.NoError(t, cc.Update(start.Add(2*time.Minute), liIonPackFull, -0.2))
+	require.Equal(t, float32(100), cc.Percent())
+}
+
+func TestCoulombCounterCapacityFade(t *testing.T) {
+	defer newFs(t, "")()
+	cc := newTestCoulombCounter(t, 10)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, cc.Update(start, liIonPackFull, 0))
+	require.Equal(t, float32(100), cc.Percent())
+
+	// Drawing 4Ah takes the resting voltage from 100% to 50%, so the pack
+	// has closer to 8Ah capacity rather than the rated 10Ah.
+	m := 0
+	for ; m < 240; m++ {
+		require.NoError(t, cc.Update(start.Add(time.Duration(m)*time.Minute), 13.5, 1))
+	}
+	for ; m < 300; m++ {
+		require.NoError(t, cc.Update(start.Add(time.Duration(m)*time.Minute), liIonPack50, 0))
+	}
+	require.InDelta(t, 50, cc.Percent(), 0.1)
+	require.InDelta(t, 9.6, cc.CapacityAh(), 0.05)
+	require.InDelta(t, 0.04, cc.CapacityFade(), 0.01)
+
+	// Small swings aren't used to estimate capacity.
+	for ; m < 330; m++ {
+		require.NoError(t, cc.Update(start.Add(time.Duration(m)*time.Minute), 13.5, 1))
+	}
+	for ; m < 400; m++ {
+		require.NoError(t, cc.Update(start.Add(time.Duration(m)*time.Minute), 14.0, 0))
+	}
+	require.InDelta(t, 9.6, cc.CapacityAh(), 0.05)
+}
+
+func TestCoulombCounterPersistence(t *testing.T) {
+	defer newFs(t, "")()
+	cc := newTestCoulombCounter(t, 10)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, cc.Update(start, liIonPack50, 0))
+	for m := 1; m <= 60; m++ {
+		require.NoError(t, cc.Update(start.Add(time.Duration(m)*time.Minute), 13.0, 1))
+	}
+	require.NoError(t, cc.Save())
+	percent := cc.Percent()
+
+	// After a reboot the count continues from the saved state, the time
+	// between the last reading and the first reading after the reboot isn't counted.
+	cc = newTestCoulombCounter(t, 10)
+	require.Equal(t, percent, cc.Percent())
+	require.NoError(t, cc.Update(start.Add(3*time.Hour), 13.0, 1))
+	require.Equal(t, percent, cc.Percent())
+
+	// State for a different pack is not used.
+	cc = newTestCoulombCounter(t, 20)
+	require.Equal(t, float32(-1), cc.Percent())
+	require.NoError(t, cc.Update(start, liIonPack20, 0))
+	require.InDelta(t, 20, cc.Percent(), 0.1)
+}
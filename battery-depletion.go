@@ -0,0 +1,203 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// Readings are grouped into buckets of this size and reduced to their median
+	// before fitting, this smooths out noise from the ADC and keeps the fit cheap.
+	depletionBucketSize = 15 * time.Minute
+	// Minimum span of readings needed before a rate is estimated.
+	depletionMinSpan = time.Hour
+)
+
+var (
+	ErrDepletionDisabled        = errors.New("depletion estimation is disabled")
+	ErrNotEnoughBatteryReadings = errors.New("not enough battery readings to estimate depletion")
+)
+
+// DepletionEstimate is the result of fitting the discharge rate over the history window.
+type DepletionEstimate struct {
+	Percent       float32       // Fitted state of charge at the time of the latest reading
+	RatePerHour   float32       // Change in percent per hour, negative when discharging
+	TimeRemaining time.Duration // Estimated time until empty, -1 if the battery is not discharging
+	Warning       bool          // True when TimeRemaining is under DepletionWarningHours
+	LatestReading time.Time
+	ReadingsInFit int
+}
+
+// Discharging returns true if the battery was discharging over the history window.
+func (de DepletionEstimate) Discharging() bool {
+	return de.TimeRemaining >= 0
+}
+
+type batteryReading struct {
+	time    time.Time
+	percent float32
+}
+
+// DepletionEstimator estimates the time until the battery is empty from
+// timestamped pack voltages, using the depletion settings of the battery section.
+type DepletionEstimator struct {
+	pack         *BatteryPack
+	history      time.Duration
+	warningHours float32
+	readings     []batteryReading
+}
+
+// NewDepletionEstimator creates a DepletionEstimator for the given pack using
+// DepletionHistoryHours and DepletionWarningHours from the battery config.
+func NewDepletionEstimator(b Battery, pack *BatteryPack) (*DepletionEstimator, error) {
+	if b.DepletionHistoryHours == 0 {
+		return nil, ErrDepletionDisabled
+	}
+	if err := batteryValidateFunc(b); err != nil {
+		return nil, err
+	}
+	if pack == nil || pack.Type == nil {
+		return nil, fmt.Errorf("no battery pack defined")
+	}
+	return &DepletionEstimator{
+		pack:         pack,
+		history:      time.Duration(b.DepletionHistoryHours) * time.Hour,
+		warningHours: b.DepletionWarningHours,
+	}, nil
+}
+
+// AddReading adds a pack voltage reading taken at the given time. Readings
+// older than the history window, relative to the latest reading, are dropped.
+func (de *DepletionEstimator) AddReading(t time.Time, voltage float32) error {
+	percent, err := de.pack.VoltageToPercent(voltage)
+	if err != nil {
+		return err
+	}
+
+	// Keep readings sorted by time, readings will nearly always be added in order.
+	i := sort.Search(len(de.readings), func(i int) bool {
+		return de.readings[i].time.After(t)
+	})
+	de.readings = append(de.readings, batteryReading{})
+	copy(de.readings[i+1:], de.readings[i:])
+	de.readings[i] = batteryReading{time: t, percent: percent}
+
+	cutoff := de.readings[len(de.readings)-1].time.Add(-de.history)
+	drop := 0
+	for drop < len(de.readings) && de.readings[drop].time.Before(cutoff) {
+		drop++
+	}
+	de.readings = de.readings[drop:]
+	return nil
+}
+
+// Reset clears all readings, for example after the battery has been replaced.
+func (de *DepletionEstimator) Reset() {
+	de.readings = nil
+}
+
+// Estimate fits the discharge rate over the history window and returns the
+// estimated time until the battery is empty.
+//
+// Readings are first reduced to the median of each 15 minute bucket to remove
+// noise, then the rate is found with a Theil-Sen fit (the median of the slopes
+// between every pair of buckets) so short rises from solar charging or load
+// changes don't drag the estimate.
+func (de *DepletionEstimator) Estimate() (DepletionEstimate, error) {
+	if len(de.readings) < 2 {
+		return DepletionEstimate{}, ErrNotEnoughBatteryReadings
+	}
+	latest := de.readings[len(de.readings)-1].time
+	if latest.Sub(de.readings[0].time) < depletionMinSpan {
+		return DepletionEstimate{}, ErrNotEnoughBatteryReadings
+	}
+
+	// Hours relative to the latest reading, so the intercept is the current percent.
+	xs, ys := de.bucketReadings(latest)
+	if len(xs) < 2 {
+		return DepletionEstimate{}, ErrNotEnoughBatteryReadings
+	}
+
+	slopes := make([]float64, 0, len(xs)*(len(xs)-1)/2)
+	for i := range xs {
+		for j := i + 1; j < len(xs); j++ {
+			slopes = append(slopes, (ys[j]-ys[i])/(xs[j]-xs[i]))
+		}
+	}
+	rate := median(slopes)
+
+	intercepts := make([]float64, len(xs))
+	for i := range xs {
+		intercepts[i] = ys[i] - rate*xs[i]
+	}
+	percent := math.Min(math.Max(median(intercepts), 0), 100)
+
+	estimate := DepletionEstimate{
+		Percent:       float32(percent),
+		RatePerHour:   float32(rate),
+		TimeRemaining: -1,
+		LatestReading: latest,
+		ReadingsInFit: len(de.readings),
+	}
+	if rate < 0 {
+		hours := percent / -rate
+		estimate.TimeRemaining = time.Duration(hours * float64(time.Hour))
+		estimate.Warning = de.warningHours > 0 && hours < float64(de.warningHours)
+	}
+	return estimate, nil
+}
+
+// bucketReadings groups readings into buckets and returns the median time,
+// in hours relative to ref, and median percent of each bucket.
+func (de *DepletionEstimator) bucketReadings(ref time.Time) (xs, ys []float64) {
+	var times, percents []float64
+	flush := func() {
+		if len(times) == 0 {
+			return
+		}
+		xs = append(xs, median(times))
+		ys = append(ys, median(percents))
+		times, percents = times[:0], percents[:0]
+	}
+
+	bucketStart := de.readings[0].time
+	for _, r := range de.readings {
+		if r.time.Sub(bucketStart) >= depletionBucketSize {
+			flush()
+			bucketStart = r.time
+		}
+		times = append(times, r.time.Sub(ref).Hours())
+		percents = append(percents, float64(r.percent))
+	}
+	flush()
+	return xs, ys
+}
+
+// median returns the median of the values, the slice is sorted in place.
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// lifePO4PackVoltage returns the 4 cell LiFePO4 pack voltage for the given percent.
+func lifePO4PackVoltage(percent float32) float32 {
+	c := LiFePO4Chemistry
+	for i := 1; i < len(c.Percent); i++ {
+		if percent <= c.Percent[i] {
+			frac := (percent - c.Percent[i-1]) / (c.Percent[i] - c.Percent[i-1])
+			return 4 * (c.Voltages[i-1] + frac*(c.Voltages[i]-c.Voltages[i-1]))
+		}
+	}
+	return 4 * c.MaxVoltage
+}
+
+func newTestDepletionEstimator(t *testing.T) *DepletionEstimator {
+	b := DefaultBattery()
+	b.Chemistry = ChemistryLiFePO4
+	pack, err := b.GetBatteryPack(13)
+	require.NoError(t, err)
+	de, err := NewDepletionEstimator(b, pack)
+	require.NoError(t, err)
+	return de
+}
+
+func TestDepletionEstimatorDisabled(t *testing.T) {
+	b := DefaultBattery()
+	b.DepletionHistoryHours = 0
+	_, err := NewDepletionEstimator(b, &BatteryPack{Type: &LiFePO4Chemistry, CellCount: 4})
+	require.ErrorIs(t, err, ErrDepletionDisabled)
+}
+
+func TestDepletionEstimatorNotEnoughReadings(t *testing.T) {
+	de := newTestDepletionEstimator(t)
+	_, err := de.Estimate()
+	require.ErrorIs(t, err, ErrNotEnoughBatteryReadings)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, de.AddReading(start, lifePO4PackVoltage(80)))
+	require.NoError(t, de.AddReading(start.Add(30*time.Minute), lifePO4PackVoltage(79)))
+	_, err = de.Estimate()
+	require.ErrorIs(t, err, ErrNotEnoughBatteryReadings)
+}
+
+func TestDepletionEstimatorSteadyDischarge(t *testing.T) {
+	de := newTestDepletionEstimator(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// 1% per hour over 24 hours, from 80% down to 56%.
+	for m := 0; m <= 24*60; m += 5 {
+		percent := 80 - float32(m)/60
+		require.NoError(t, de.AddReading(start.Add(time.Duration(m)*time.Minute), lifePO4PackVoltage(percent)))
+	}
+
+	estimate, err := de.Estimate()
+	require.NoError(t, err)
+	require.True(t, estimate.Discharging())
+	require.InDelta(t, -1, estimate.RatePerHour, 0.05)
+	require.InDelta(t, 56, estimate.Percent, 0.5)
+	require.InDelta(t, 56, estimate.TimeRemaining.Hours(), 3)
+	require.False(t, estimate.Warning)
+}
+
+func TestDepletionEstimatorIgnoresSpikes(t *testing.T) {
+	de := newTestDepletionEstimator(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// 2% per hour, with a two hour solar charge spike and some noisy readings.
+	for m := 0; m <= 12*60; m += 5 {
+		percent := 30 - 2*float32(m)/60
+		if m >= 4*60 && m < 6*60 {
+			percent = 100
+		}
+		if m%45 == 0 {
+			percent += 15
+		}
+		require.NoError(t, de.AddReading(start.Add(time.Duration(m)*time.Minute), lifePO4PackVoltage(percent)))
+	}
+
+	estimate, err := de.Estimate()
+	require.NoError(t, err)
+	require.InDelta(t, -2, estimate.RatePerHour, 0.3)
+	require.InDelta(t, 3, estimate.TimeRemaining.Hours(), 1)
+	require.True(t, estimate.Warning)
+}
+
+func TestDepletionEstimatorCharging(t *testing.T) {
+	de := newTestDepletionEstimator(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for h := 0; h <= 6; h++ {
+		require.NoError(t, de.AddReading(start.Add(time.Duration(h)*time.Hour), lifePO4PackVoltage(40+5*float32(h))))
+	}
+
+	estimate, err := de.Estimate()
+	require.NoError(t, err)
+	require.False(t, estimate.Discharging())
+	require.False(t, estimate.Warning)
+}
+
+func TestDepletionEstimatorHistoryWindow(t *testing.T) {
+	b := DefaultBattery()
+	b.DepletionHistoryHours = 2
+	de, err := NewDepletionEstimator(b, &BatteryPack{Type: &LiFePO4Chemistry, CellCount: 4})
+	require.NoError(t, err)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for h := 0; h <= 10; h++ {
+		require.NoError(t, de.AddReading(start.Add(time.Duration(h)*time.Hour), lifePO4PackVoltage(80-float32(h))))
+	}
+	require.Len(t, de.readings, 3)
+}
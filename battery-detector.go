@@ -0,0 +1,188 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import "fmt"
+
+// Default tuning for the BatteryDetector
+const (
+	DefaultDetectorWindow            = 60  // Number of recent readings that detections are counted over
+	DefaultDetectorMinReadings       = 12  // Readings needed before full confidence can be reached
+	DefaultDetectorCommitConfidence  = 0.9 // Confidence needed to commit to a new detection
+	DefaultDetectorReleaseConfidence = 0.5 // Confidence the committed detection has to drop below before it can change
+)
+
+type batteryDetection struct {
+	chemistry string
+	cells     int
+}
+
+// BatteryDetector detects the battery chemistry and cell count from readings
+// accumulated over time. Unlike AutoDetectBatteryPack it only commits to a
+// detection once it is confident, and will only change a committed detection
+// when another one is clearly more likely, so the detected pack doesn't flip
+// between chemistries as the battery discharges.
+type BatteryDetector struct {
+	Window            int
+	MinReadings       int
+	CommitConfidence  float32
+	ReleaseConfidence float32
+
+	minimumVoltage float32
+	observedMin    float32
+	observedMax    float32
+	readings       int
+	recent         []batteryDetection
+	committed      *batteryDetection
+}
+
+// NewBatteryDetector creates a BatteryDetector using the battery config. If the
+// config already has a chemistry and cell count they are used as the starting
+// detection so a restart doesn't lose the previous result.
+func NewBatteryDetector(b Battery) *BatteryDetector {
+	bd := &BatteryDetector{
+		Window:            DefaultDetectorWindow,
+		MinReadings:       DefaultDetectorMinReadings,
+		CommitConfidence:  DefaultDetectorCommitConfidence,
+		ReleaseConfidence: DefaultDetectorReleaseConfidence,
+		minimumVoltage:    b.MinimumVoltageDetection,
+		observedMin:       -1,
+		observedMax:       -1,
+	}
+	cells := b.ManualCellCount
+	if cells == 0 {
+		cells = b.DetectedCellCount
+	}
+	if _, ok := ChemistryProfiles[b.Chemistry]; ok && cells > 0 {
+		bd.committed = &batteryDetection{chemistry: b.Chemistry, cells: cells}
+	}
+	return bd
+}
+
+// AddReading adds a pack voltage reading. Readings below the configured minimum
+// detection voltage are ignored as there is probably no battery connected.
+func (bd *BatteryDetector) AddReading(voltage float32) error {
+	if voltage < bd.minimumVoltage || voltage <= 0 {
+		return nil
+	}
+
+	if bd.observedMin == -1 || voltage < bd.observedMin {
+		bd.observedMin = voltage
+	}
+	if bd.observedMax == -1 || voltage > bd.observedMax {
+		bd.observedMax = voltage
+	}
+
+	pack, err := AutoDetectBatteryPack(voltage, bd.observedMin, bd.observedMax)
+	if err != nil {
+		return err
+	}
+	bd.readings++
+	bd.recent = append(bd.recent, batteryDetection{chemistry: pack.Type.Chemistry, cells: pack.CellCount})
+	if len(bd.recent) > bd.Window {
+		bd.recent = bd.recent[len(bd.recent)-bd.Window:]
+	}
+
+	leader, confidence := bd.leader()
+	if bd.committed == nil || *bd.committed == leader {
+		if confidence >= bd.CommitConfidence {
+			bd.committed = &leader
+		}
+		return nil
+	}
+	if bd.confidence(*bd.committed) < bd.ReleaseConfidence && confidence >= bd.CommitConfidence {
+		bd.committed = &leader
+	}
+	return nil
+}
+
+// Detected returns the committed battery pack and the confidence in it, from 0 to 1.
+// The pack is nil if there is no committed detection yet.
+func (bd *BatteryDetector) Detected() (*BatteryPack, float32) {
+	if bd.committed == nil {
+		return nil, 0
+	}
+	profile := ChemistryProfiles[bd.committed.chemistry]
+	return &BatteryPack{Type: &profile, CellCount: bd.committed.cells}, bd.confidence(*bd.committed)
+}
+
+// Confidence returns the confidence in the committed detection, from 0 to 1.
+func (bd *BatteryDetector) Confidence() float32 {
+	_, confidence := bd.Detected()
+	return confidence
+}
+
+// Save writes the committed chemistry and cell count to the battery section.
+// Nothing is written if there is no committed detection, it hasn't changed,
+// or the battery has been manually configured.
+// Returns true if the battery section was updated.
+func (bd *BatteryDetector) Save(c *Config) (bool, error) {
+	if bd.committed == nil {
+		return false, nil
+	}
+	var b Battery
+	if err := c.Unmarshal(BatteryKey, &b); err != nil {
+		return false, err
+	}
+	if b.ManuallyConfigured {
+		return false, nil
+	}
+	if b.Chemistry == bd.committed.chemistry && b.DetectedCellCount == bd.committed.cells {
+		return false, nil
+	}
+	err := c.SetFromMap(BatteryKey, map[string]interface{}{
+		"chemistry":           bd.committed.chemistry,
+		"detected-cell-count": bd.committed.cells,
+	}, false)
+	if err != nil {
+		return false, fmt.Errorf("failed to save detected battery: %w", err)
+	}
+	return true, nil
+}
+
+// leader returns the most common detection in the recent readings and the confidence in it.
+func (bd *BatteryDetector) leader() (batteryDetection, float32) {
+	counts := map[batteryDetection]int{}
+	var leader batteryDetection
+	for _, d := range bd.recent {
+		counts[d]++
+		// Ties go to the most recent detection.
+		if counts[d] >= counts[leader] {
+			leader = d
+		}
+	}
+	return leader, bd.confidence(leader)
+}
+
+// confidence is the share of recent readings matching the detection, scaled
+// down until enough readings have been taken.
+func (bd *BatteryDetector) confidence(d batteryDetection) float32 {
+	if len(bd.recent) == 0 {
+		return 0
+	}
+	matches := 0
+	for _, r := range bd.recent {
+		if r == d {
+			matches++
+		}
+	}
+	confidence := float32(matches) / float32(len(bd.recent))
+	if bd.readings < bd.MinReadings {
+		confidence *= float32(bd.readings) / float32(bd.MinReadings)
+	}
+	return confidence
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatteryDetectorNeedsConfidence(t *testing.T) {
+	bd := NewBatteryDetector(DefaultBattery())
+	// 10 of 12 readings isn't enough to reach the commit confidence.
+	for range 10 {
+		require.NoError(t, bd.AddReading(7.8))
+	}
+	pack, confidence := bd.Detected()
+	require.Nil(t, pack)
+	require.Zero(t, confidence)
+
+	for range 2 {
+		require.NoError(t, bd.AddReading(7.8))
+	}
+	pack, confidence = bd.Detected()
+	require.NotNil(t, pack)
+	require.Equal(t, ChemistryLiIon, pack.Type.Chemistry)
+	require.Equal(t, 2, pack.CellCount)
+	require.Equal(t, float32(1), confidence)
+}
+
+func TestBatteryDetectorIgnoresLowVoltage(t *testing.T) {
+	bd := NewBatteryDetector(DefaultBattery())
+	for range 20 {
+		require.NoError(t, bd.AddReading(0.2))
+	}
+	pack, _ := bd.Detected()
+	require.Nil(t, pack)
+}
+
+func TestBatteryDetectorHysteresis(t *testing.T) {
+	bd := NewBatteryDetector(DefaultBattery())
+
+	// A LiFePO4 2S pack near full reads like a Li-ion 2S pack.
+	for range 20 {
+		require.NoError(t, bd.AddReading(6.6))
+	}
+	pack, _ := bd.Detected()
+	require.Equal(t, ChemistryLiIon, pack.Type.Chemistry)
+
+	// Once it discharges below the Li-ion range it is clearly LiFePO4, a few
+	// readings aren't enough to change the committed detection.
+	for range 5 {
+		require.NoError(t, bd.AddReading(6.2))
+	}
+	pack, confidence := bd.Detected()
+	require.Equal(t, ChemistryLiIon, pack.Type.Chemistry)
+	require.Less(t, confidence, float32(1))
+
+	for range 60 {
+		require.NoError(t, bd.AddReading(6.2))
+	}
+	pack, confidence = bd.Detected()
+	require.Equal(t, ChemistryLiFePO4, pack.Type.Chemistry)
+	require.Equal(t, 2, pack.CellCount)
+	require.GreaterOrEqual(t, confidence, float32(DefaultDetectorCommitConfidence))
+
+	// Recharging back into the overlapping range won't flip it back to Li-ion
+	// as the observed minimum voltage is outside the Li-ion range.
+	for range 60 {
+		require.NoError(t, bd.AddReading(6.6))
+	}
+	pack, _ = bd.Detected()
+	require.Equal(t, ChemistryLiFePO4, pack.Type.Chemistry)
+}
+
+func TestBatteryDetectorStartsFromConfig(t *testing.T) {
+	b := DefaultBattery()
+	b.Chemistry = ChemistryLeadAcid
+	b.ManualCellCount = 6
+	bd := NewBatteryDetector(b)
+	pack, confidence := bd.Detected()
+	require.Equal(t, ChemistryLeadAcid, pack.Type.Chemistry)
+	require.Equal(t, 6, pack.CellCount)
+	require.Zero(t, confidence)
+}
+
+func TestBatteryDetectorSave(t *testing.T) {
+	defer newFs(t, "")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	require.NoError(t, conf.SetFromMap(BatteryKey, map[string]interface{}{"depletion-warning-hours": 5}, false))
+
+	bd := NewBatteryDetector(DefaultBattery())
+	saved, err := bd.Save(conf)
+	require.NoError(t, err)
+	require.False(t, saved)
+
+	for range 20 {
+		require.NoError(t, bd.AddReading(7.8))
+	}
+	saved, err = bd.Save(conf)
+	require.NoError(t, err)
+	require.True(t, saved)
+
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	var b Battery
+	require.NoError(t, conf.Unmarshal(BatteryKey, &b))
+	require.Equal(t, ChemistryLiIon, b.Chemistry)
+	require.Equal(t, 2, b.DetectedCellCount)
+	require.Zero(t, b.ManualCellCount)
+	require.False(t, b.ManuallyConfigured)
+	require.Equal(t, float32(5), b.DepletionWarningHours)
+
+	saved, err = bd.Save(conf)
+	require.NoError(t, err)
+	require.False(t, saved)
+}
+
+func TestBatteryDetectorSaveManuallyConfigured(t *testing.T) {
+	defer newFs(t, "")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	b := DefaultBattery()
+	require.NoError(t, b.SetManualConfiguration(ChemistryLeadAcid, 6))
+	require.NoError(t, conf.Set(BatteryKey, b))
+
+	bd := NewBatteryDetector(DefaultBattery())
+	for range 20 {
+		require.NoError(t, bd.AddReading(7.8))
+	}
+	saved, err := bd.Save(conf)
+	require.NoError(t, err)
+	require.False(t, saved)
+
+	require.NoError(t, conf.Unmarshal(BatteryKey, &b))
+	require.Equal(t, ChemistryLeadAcid, b.Chemistry)
+}
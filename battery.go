@@ -19,23 +19,34 @@ package config
 import (
 	"fmt"
 	"math"
+	"reflect"
 	"sort"
+
+	"github.com/mitchellh/mapstructure"
 )
 
 // Battery configuration constants
 const (
 	BatteryKey = "battery"
 
-	// Voltage rails
-	RailHV   = "hv"
-	RailLV   = "lv"
-	RailAuto = "auto"
-
 	ChemistryLeadAcid = "lead-acid"
 	ChemistryLiFePO4  = "lifepo4"
 	ChemistryLiIon    = "li-ion"
 	ChemistryLiPo     = "lipo"
 	ChemistryCustom   = "custom"
+
+	// Temperature the chemistry curves and compensation coefficients are referenced to.
+	CompensationReferenceTemp float32 = 25.0
+)
+
+// BatteryRail is one of the battery inputs on the board
+type BatteryRail string
+
+// Voltage rails
+const (
+	RailHV   = "hv"
+	RailLV   = "lv"
+	RailAuto = "auto"
 )
 
 func init() {
@@ -50,6 +61,7 @@ func init() {
 			return &Battery{}
 		},
 	}
+	allSectionDecodeHookFuncs = append(allSectionDecodeHookFuncs, batteryToMap)
 }
 
 // Battery represents the main battery configuration
@@ -57,10 +69,26 @@ type Battery struct {
 	Chemistry               string  `mapstructure:"chemistry"`
 	ManualCellCount         int     `mapstructure:"manual-cell-count"`
 	ManuallyConfigured      bool    `mapstructure:"manually-configured"`
+	DetectedCellCount       int     `mapstructure:"detected-cell-count"` // Saved by the BatteryDetector
 	MinimumVoltageDetection float32 `mapstructure:"minimum-voltage-detection"`
 	DepletionHistoryHours   int     `mapstructure:"depletion-history-hours"`
 	DepletionWarningHours   float32 `mapstructure:"depletion-warning-hours"`
-	Updated                 any     `mapstructure:"updated,omitempty"`
+
+	// Which battery input is in use, "hv", "lv" or "auto" to pick from the readings.
+	// Per rail chemistry and cell count override Chemistry and ManualCellCount for that rail.
+	Rail        BatteryRail `mapstructure:"rail"`
+	HVChemistry string      `mapstructure:"hv-chemistry"`
+	HVCellCount int         `mapstructure:"hv-cell-count"`
+	LVChemistry string      `mapstructure:"lv-chemistry"`
+	LVCellCount int         `mapstructure:"lv-cell-count"`
+
+	// Single-cell discharge curve used when Chemistry is "custom", see CalibrateDischargeCurve
+	CustomMinVoltage float32   `mapstructure:"custom-min-voltage"`
+	CustomMaxVoltage float32   `mapstructure:"custom-max-voltage"`
+	CustomVoltages   []float32 `mapstructure:"custom-voltages"`
+	CustomPercent    []float32 `mapstructure:"custom-percent"`
+
+	Updated any `mapstructure:"updated,omitempty"`
 }
 
 // DefaultBattery returns default battery configuration
@@ -69,12 +97,94 @@ func DefaultBattery() Battery {
 		MinimumVoltageDetection: 1.0,
 		DepletionHistoryHours:   48,
 		DepletionWarningHours:   12.0,
+		Rail:                    RailAuto,
+	}
+}
+
+// RailConfig returns the chemistry and cell count configured for the rail,
+// falling back to Chemistry and ManualCellCount when not set for the rail.
+func (b *Battery) RailConfig(rail BatteryRail) (string, int) {
+	chemistry, cellCount := b.Chemistry, b.ManualCellCount
+	var railChemistry string
+	var railCellCount int
+	switch rail {
+	case RailHV:
+		railChemistry, railCellCount = b.HVChemistry, b.HVCellCount
+	case RailLV:
+		railChemistry, railCellCount = b.LVChemistry, b.LVCellCount
+	}
+	if railChemistry != "" {
+		chemistry = railChemistry
+	}
+	if railCellCount > 0 {
+		cellCount = railCellCount
+	}
+	return chemistry, cellCount
+}
+
+// GetRailBatteryPack creates a BatteryPack for the rail from config and voltage reading
+func (b *Battery) GetRailBatteryPack(rail BatteryRail, voltage float32) (*BatteryPack, error) {
+	if rail != RailHV && rail != RailLV {
+		return nil, fmt.Errorf("invalid battery rail: '%s'", rail)
 	}
+	railBattery := *b
+	railBattery.Chemistry, railBattery.ManualCellCount = b.RailConfig(rail)
+	// DetectedCellCount was saved for a single pack, so detect from the rail's own voltage.
+	railBattery.DetectedCellCount = 0
+	return railBattery.GetBatteryPack(voltage)
+}
+
+// SelectRail returns the rail in use given voltage readings from both battery inputs.
+// If the rail is set to "hv" or "lv" that rail is returned. Under "auto" the precedence is:
+//  1. A rail reading below MinimumVoltageDetection has no battery connected.
+//  2. If only one rail has a battery connected, that rail is used.
+//  3. If both do, the rail whose configured chemistry and cell count matches its
+//     reading is used. Rails without a configured chemistry never match.
+//  4. Otherwise the HV rail is used.
+func (b *Battery) SelectRail(hvVoltage, lvVoltage float32) (BatteryRail, error) {
+	switch b.Rail {
+	case RailHV, RailLV:
+		return b.Rail, nil
+	case RailAuto, "":
+	default:
+		return "", fmt.Errorf("invalid battery rail: '%s'", b.Rail)
+	}
+
+	hvConnected := hvVoltage > 0 && hvVoltage >= b.MinimumVoltageDetection
+	lvConnected := lvVoltage > 0 && lvVoltage >= b.MinimumVoltageDetection
+	switch {
+	case !hvConnected && !lvConnected:
+		return "", fmt.Errorf("no battery detected on either rail (hv %.2fV, lv %.2fV)", hvVoltage, lvVoltage)
+	case !lvConnected:
+		return RailHV, nil
+	case !hvConnected:
+		return RailLV, nil
+	}
+
+	hvMatches := b.railMatchesVoltage(RailHV, hvVoltage)
+	lvMatches := b.railMatchesVoltage(RailLV, lvVoltage)
+	if lvMatches && !hvMatches {
+		return RailLV, nil
+	}
+	return RailHV, nil
+}
+
+// railMatchesVoltage returns true if the voltage is within the range of the pack configured for the rail.
+func (b *Battery) railMatchesVoltage(rail BatteryRail, voltage float32) bool {
+	chemistry, _ := b.RailConfig(rail)
+	if chemistry == "" {
+		return false
+	}
+	pack, err := b.GetRailBatteryPack(rail, voltage)
+	if err != nil || pack.CellCount <= 0 {
+		return false
+	}
+	return voltage >= pack.GetScaledMinVoltage() && voltage <= pack.GetScaledMaxVoltage()
 }
 
 // NewBatteryPack creates a new battery pack with the specified chemistry and cell count
 func (b *Battery) NewBatteryPack(chemistry string, cellCount int) (*BatteryPack, error) {
-	batteryType, exists := ChemistryProfiles[chemistry]
+	batteryType, exists := b.chemistryProfile(chemistry)
 	if !exists {
 		return nil, fmt.Errorf("unknown chemistry: %s", chemistry)
 	}
@@ -87,7 +197,7 @@ func (b *Battery) NewBatteryPack(chemistry string, cellCount int) (*BatteryPack,
 
 // DetectCellCount estimates cell count for a given chemistry and voltage
 func (b *Battery) DetectCellCount(chemistry string, voltage float32) int {
-	batteryType, exists := ChemistryProfiles[chemistry]
+	batteryType, exists := b.chemistryProfile(chemistry)
 	if !exists {
 		return 0
 	}
@@ -105,6 +215,10 @@ type BatteryType struct {
 	// Single-cell discharge curve
 	Voltages []float32 `mapstructure:"voltages"`
 	Percent  []float32 `mapstructure:"percent"`
+
+	// Single-cell compensation coefficients, used by VoltageToPercentCompensated
+	TempCoefficient    float32 `mapstructure:"temp-coefficient"`    // Change in resting voltage per degree C away from the reference temperature
+	InternalResistance float32 `mapstructure:"internal-resistance"` // Ohms, used to correct for voltage sag while under load
 }
 
 // BatteryPack represents a complete battery pack with chemistry and cell count
@@ -203,6 +317,35 @@ func (bp *BatteryPack) VoltageToPercent(voltage float32) (float32, error) {
 	return percent, nil
 }
 
+// CompensatedVoltage estimates the resting pack voltage at the reference temperature
+// from a reading taken at tempC while drawing currentA (positive when discharging).
+// Pass NaN for tempC if the temperature is unknown.
+func (bp *BatteryPack) CompensatedVoltage(voltage, tempC, currentA float32) float32 {
+	if bp.Type == nil || bp.CellCount <= 0 {
+		return voltage
+	}
+	cells := float32(bp.CellCount)
+
+	// Add back the voltage lost across the internal resistance of the cells.
+	voltage += currentA * bp.Type.InternalResistance * cells
+
+	// Resting voltage drops as the cells get colder.
+	if !math.IsNaN(float64(tempC)) {
+		voltage -= bp.Type.TempCoefficient * (tempC - CompensationReferenceTemp) * cells
+	}
+	return voltage
+}
+
+// VoltageToPercentCompensated converts pack voltage to percentage after correcting
+// for the temperature of the pack and the current being drawn from it.
+// Pass NaN for tempC if the temperature is unknown and 0 for currentA if the current is unknown.
+func (bp *BatteryPack) VoltageToPercentCompensated(voltage, tempC, currentA float32) (float32, error) {
+	if bp.Type == nil {
+		return -1, fmt.Errorf("no battery type defined")
+	}
+	return bp.VoltageToPercent(bp.CompensatedVoltage(voltage, tempC, currentA))
+}
+
 // NormalizeCurves ensures voltage curves are properly set with backward compatibility
 func (bt *BatteryType) NormalizeCurves() {
 	// Set chemistry if not specified
@@ -226,6 +369,9 @@ var LiFePO4Chemistry = BatteryType{
 	MaxVoltage: 3.4,
 	Voltages:   []float32{2.5, 3.0, 3.2, 3.22, 3.25, 3.26, 3.27, 3.3, 3.32, 3.35, 3.4},
 	Percent:    []float32{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100},
+
+	TempCoefficient:    0.0007,
+	InternalResistance: 0.015,
 }
 
 // Based on NMC cells
@@ -235,6 +381,9 @@ var LiIonChemistry = BatteryType{
 	MaxVoltage: 4.2,
 	Voltages:   []float32{2.5, 3.0, 3.2, 3.4, 3.5, 3.6, 3.7, 3.8, 3.9, 4.0, 4.2},
 	Percent:    []float32{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100},
+
+	TempCoefficient:    0.0008,
+	InternalResistance: 0.05,
 }
 
 var LeadAcidChemistry = BatteryType{
@@ -243,6 +392,9 @@ var LeadAcidChemistry = BatteryType{
 	MaxVoltage: 2.15,
 	Voltages:   []float32{1.94, 1.95, 1.97, 1.99, 2.02, 2.04, 2.07, 2.09, 2.11, 2.13, 2.15},
 	Percent:    []float32{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100},
+
+	TempCoefficient:    0.001,
+	InternalResistance: 0.004,
 }
 
 var LiPoChemistry = BatteryType{
@@ -251,6 +403,9 @@ var LiPoChemistry = BatteryType{
 	MaxVoltage: 4.2,
 	Voltages:   []float32{3.27, 3.69, 3.73, 3.77, 3.8, 3.84, 3.87, 3.95, 4.02, 4.11, 4.2},
 	Percent:    []float32{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100},
+
+	TempCoefficient:    0.0008,
+	InternalResistance: 0.03,
 }
 
 // GetBatteryPack creates a BatteryPack from config and voltage reading
@@ -259,7 +414,7 @@ func (b *Battery) GetBatteryPack(voltage float32) (*BatteryPack, error) {
 		return nil, fmt.Errorf("no battery chemistry specified")
 	}
 
-	chemistryProfile, exists := ChemistryProfiles[b.Chemistry]
+	chemistryProfile, exists := b.chemistryProfile(b.Chemistry)
 	if !exists {
 		return nil, fmt.Errorf("unknown battery chemistry: %s", b.Chemistry)
 	}
@@ -268,9 +423,11 @@ func (b *Battery) GetBatteryPack(voltage float32) (*BatteryPack, error) {
 		Type: &chemistryProfile,
 	}
 
-	// Use manual cell count if configured, otherwise detect from voltage
+	// Use manual cell count if configured, then the saved detection, otherwise detect from voltage
 	if b.ManualCellCount > 0 {
 		pack.CellCount = b.ManualCellCount
+	} else if b.DetectedCellCount > 0 {
+		pack.CellCount = b.DetectedCellCount
 	} else if voltage > 0 {
 		pack.CellCount = pack.DetectCellCount(voltage)
 	}
@@ -284,7 +441,7 @@ func (b *Battery) GetChemistryProfile() (*BatteryType, error) {
 		return nil, fmt.Errorf("no battery chemistry specified")
 	}
 
-	chemistryProfile, exists := ChemistryProfiles[b.Chemistry]
+	chemistryProfile, exists := b.chemistryProfile(b.Chemistry)
 	if !exists {
 		return nil, fmt.Errorf("unknown battery chemistry: %s", b.Chemistry)
 	}
@@ -292,6 +449,45 @@ func (b *Battery) GetChemistryProfile() (*BatteryType, error) {
 	return &chemistryProfile, nil
 }
 
+// chemistryProfile returns the profile for the chemistry, including the custom
+// profile if the chemistry is "custom" and a custom curve has been set.
+func (b *Battery) chemistryProfile(chemistry string) (BatteryType, bool) {
+	if chemistry == ChemistryCustom {
+		if len(b.CustomVoltages) == 0 {
+			return BatteryType{}, false
+		}
+		return b.CustomProfile(), true
+	}
+	profile, exists := ChemistryProfiles[chemistry]
+	return profile, exists
+}
+
+// CustomProfile returns the custom discharge curve as a BatteryType
+func (b *Battery) CustomProfile() BatteryType {
+	return BatteryType{
+		Chemistry:  ChemistryCustom,
+		MinVoltage: b.CustomMinVoltage,
+		MaxVoltage: b.CustomMaxVoltage,
+		Voltages:   append([]float32{}, b.CustomVoltages...),
+		Percent:    append([]float32{}, b.CustomPercent...),
+	}
+}
+
+// SetCustomProfile sets a custom discharge curve, such as one from
+// CalibrateDischargeCurve, and manually configures the battery to use it.
+func (b *Battery) SetCustomProfile(profile BatteryType) error {
+	if err := validateDischargeCurve(profile.Voltages, profile.Percent); err != nil {
+		return err
+	}
+	b.CustomMinVoltage = profile.MinVoltage
+	b.CustomMaxVoltage = profile.MaxVoltage
+	b.CustomVoltages = append([]float32{}, profile.Voltages...)
+	b.CustomPercent = append([]float32{}, profile.Percent...)
+	b.Chemistry = ChemistryCustom
+	b.ManuallyConfigured = true
+	return nil
+}
+
 // GetBatteryType returns the configured battery chemistry profile
 // Deprecated: Use GetChemistryProfile or GetBatteryPack instead
 func (b *Battery) GetBatteryType() *BatteryType {
@@ -310,7 +506,7 @@ func (b *Battery) IsManuallyConfigured() bool {
 // SetManualChemistry sets a manual battery chemistry override
 func (b *Battery) SetManualChemistry(chemistry string) error {
 	// Validate chemistry
-	if _, exists := ChemistryProfiles[chemistry]; !exists {
+	if _, exists := b.chemistryProfile(chemistry); !exists {
 		return fmt.Errorf("unknown battery chemistry: %s", chemistry)
 	}
 
@@ -323,7 +519,7 @@ func (b *Battery) SetManualChemistry(chemistry string) error {
 func (b *Battery) SetManualConfiguration(chemistry string, cellCount int) error {
 	// Validate chemistry
 	if chemistry != "" {
-		if _, exists := ChemistryProfiles[chemistry]; !exists {
+		if _, exists := b.chemistryProfile(chemistry); !exists {
 			return fmt.Errorf("unknown battery chemistry: %s", chemistry)
 		}
 		b.Chemistry = chemistry
@@ -499,9 +695,16 @@ func batteryValidateFunc(battery any) error {
 		return err
 	}
 
+	// Validate custom curve if specified
+	if len(b.CustomVoltages) > 0 || len(b.CustomPercent) > 0 {
+		if err := validateDischargeCurve(b.CustomVoltages, b.CustomPercent); err != nil {
+			return fmt.Errorf("invalid custom battery curve: %w", err)
+		}
+	}
+
 	// Validate chemistry if specified
 	if b.Chemistry != "" {
-		if _, exists := ChemistryProfiles[b.Chemistry]; !exists {
+		if _, exists := b.chemistryProfile(b.Chemistry); !exists {
 			return fmt.Errorf("unknown battery chemistry: %s", b.Chemistry)
 		}
 	}
@@ -513,6 +716,31 @@ func batteryValidateFunc(battery any) error {
 		}
 	}
 
+	switch b.Rail {
+	case "", RailHV, RailLV, RailAuto:
+	default:
+		return fmt.Errorf("battery rail must be one of '%s', '%s' or '%s', got '%s'", RailHV, RailLV, RailAuto, b.Rail)
+	}
+
+	// Validate per rail chemistry and cell count
+	for _, rail := range []struct {
+		name      BatteryRail
+		chemistry string
+		cellCount int
+	}{
+		{RailHV, b.HVChemistry, b.HVCellCount},
+		{RailLV, b.LVChemistry, b.LVCellCount},
+	} {
+		if rail.chemistry != "" {
+			if _, exists := b.chemistryProfile(rail.chemistry); !exists {
+				return fmt.Errorf("unknown battery chemistry for %s rail: %s", rail.name, rail.chemistry)
+			}
+		}
+		if rail.cellCount != 0 && (rail.cellCount < 1 || rail.cellCount > 24) {
+			return fmt.Errorf("%s rail cell count must be between 1 and 24, got %d", rail.name, rail.cellCount)
+		}
+	}
+
 	if b.MinimumVoltageDetection < 0 {
 		return fmt.Errorf("minimum voltage detection must be >= 0")
 	}
@@ -529,6 +757,47 @@ func batteryValidateFunc(battery any) error {
 	return nil
 }
 
+// validateDischargeCurve checks the curve has matching voltages and percentages
+// that both increase, with percentages between 0 and 100.
+func validateDischargeCurve(voltages, percents []float32) error {
+	if len(voltages) != len(percents) {
+		return fmt.Errorf("curve has %d voltages and %d percentages", len(voltages), len(percents))
+	}
+	if len(voltages) < 2 {
+		return fmt.Errorf("curve needs at least 2 points, got %d", len(voltages))
+	}
+	for i := range voltages {
+		if percents[i] < 0 || percents[i] > 100 {
+			return fmt.Errorf("curve percentages must be between 0 and 100, got %v", percents[i])
+		}
+		if i > 0 && (voltages[i] < voltages[i-1] || percents[i] <= percents[i-1]) {
+			return fmt.Errorf("curve must be increasing, point %d (%vV, %v%%) is below the point before it", i, voltages[i], percents[i])
+		}
+	}
+	return nil
+}
+
+// batteryToMap converts a Battery to a map with the rail as a plain string so it can be written to the config file
+func batteryToMap(f reflect.Type, t reflect.Type, data any) (any, error) {
+	if t != mapStrInterfaceType {
+		return data, nil
+	}
+	switch f {
+	case reflect.TypeOf(&Battery{}):
+		data = *(data.(*Battery)) // follow the pointer
+		fallthrough
+	case reflect.TypeOf(Battery{}):
+		m := map[string]any{}
+		if err := mapstructure.Decode(data, &m); err != nil {
+			return nil, err
+		}
+		m["rail"] = string(data.(Battery).Rail)
+		return m, nil
+	default:
+		return data, nil
+	}
+}
+
 // batteryMapToStruct converts map to Battery struct
 func batteryMapToStruct(m map[string]any) (any, error) {
 	var s Battery
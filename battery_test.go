@@ -1,6 +1,7 @@
 package config
 
 import (
+	"math"
 	"testing"
 )
 
@@ -548,3 +549,176 @@ func TestAutoDetectBatteryPackPreference(t *testing.T) {
 		})
 	}
 }
+
+func TestVoltageToPercentCompensated(t *testing.T) {
+	nan := float32(math.NaN())
+	testCases := []struct {
+		name            string
+		chemistry       BatteryType
+		cells           int
+		voltage         float32
+		tempC           float32
+		currentA        float32
+		expectedPercent float32
+	}{
+		// 12.24V is 50% for a resting 12V lead-acid battery at 25C, the same
+		// charge reads 0.15V lower at 0C.
+		{"Lead-acid at reference temperature", LeadAcidChemistry, 6, 12.24, 25, 0, 50},
+		{"Lead-acid cold night", LeadAcidChemistry, 6, 12.09, 0, 0, 50},
+		{"Lead-acid hot day", LeadAcidChemistry, 6, 12.39, 50, 0, 50},
+		// 13.2V is 70% for a resting 4 cell LiFePO4, sagging 0.18V while drawing 3A.
+		{"LiFePO4 at rest", LiFePO4Chemistry, 4, 13.2, nan, 0, 70},
+		{"LiFePO4 modem transmitting", LiFePO4Chemistry, 4, 13.02, nan, 3, 70},
+		{"LiFePO4 charging", LiFePO4Chemistry, 4, 13.38, nan, -3, 70},
+		// 3.7V is 60% for a resting Li-ion cell, cold and under a 1A load.
+		{"Li-ion cold under load", LiIonChemistry, 1, 3.63, 0, 1, 60},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pack := &BatteryPack{Type: &tc.chemistry, CellCount: tc.cells}
+			percent, err := pack.VoltageToPercentCompensated(tc.voltage, tc.tempC, tc.currentA)
+			if err != nil {
+				t.Fatalf("Failed to convert voltage to percent: %v", err)
+			}
+			if math.Abs(float64(percent-tc.expectedPercent)) > 0.5 {
+				t.Errorf("For %.2fV at %.1fC and %.1fA, expected %.1f%%, got %.1f%%",
+					tc.voltage, tc.tempC, tc.currentA, tc.expectedPercent, percent)
+			}
+		})
+	}
+}
+
+func TestVoltageToPercentCompensatedMatchesUncompensated(t *testing.T) {
+	pack := &BatteryPack{Type: &LiPoChemistry, CellCount: 2}
+	for _, voltage := range []float32{6.5, 7.4, 7.7, 8.4} {
+		expected, err := pack.VoltageToPercent(voltage)
+		if err != nil {
+			t.Fatalf("Failed to convert voltage to percent: %v", err)
+		}
+		percent, err := pack.VoltageToPercentCompensated(voltage, CompensationReferenceTemp, 0)
+		if err != nil {
+			t.Fatalf("Failed to convert voltage to percent: %v", err)
+		}
+		if percent != expected {
+			t.Errorf("For %.2fV expected %.1f%%, got %.1f%%", voltage, expected, percent)
+		}
+	}
+
+	if _, err := (&BatteryPack{}).VoltageToPercentCompensated(7.4, 25, 0); err == nil {
+		t.Error("Expected error for pack with no battery type, got nil")
+	}
+}
+
+func TestSelectRail(t *testing.T) {
+	testCases := []struct {
+		name      string
+		battery   Battery
+		hv        float32
+		lv        float32
+		expected  BatteryRail
+		expectErr bool
+	}{
+		{"Forced HV", Battery{Rail: RailHV}, 0, 3.7, RailHV, false},
+		{"Forced LV", Battery{Rail: RailLV}, 12.5, 0, RailLV, false},
+		{"Auto only HV connected", Battery{Rail: RailAuto, MinimumVoltageDetection: 1}, 12.5, 0.3, RailHV, false},
+		{"Auto only LV connected", Battery{Rail: RailAuto, MinimumVoltageDetection: 1}, 0.2, 3.7, RailLV, false},
+		{"Auto nothing connected", Battery{Rail: RailAuto, MinimumVoltageDetection: 1}, 0.2, 0.3, "", true},
+		{"Empty rail is auto", Battery{MinimumVoltageDetection: 1}, 0, 3.7, RailLV, false},
+		{"Auto both connected, none configured", Battery{Rail: RailAuto}, 12.5, 3.7, RailHV, false},
+		{
+			"Auto both connected, only LV matches",
+			Battery{Rail: RailAuto, HVChemistry: ChemistryLeadAcid, HVCellCount: 6, LVChemistry: ChemistryLiIon, LVCellCount: 1},
+			9.0, 3.7, RailLV, false,
+		},
+		{
+			"Auto both connected, both match",
+			Battery{Rail: RailAuto, HVChemistry: ChemistryLeadAcid, HVCellCount: 6, LVChemistry: ChemistryLiIon, LVCellCount: 1},
+			12.5, 3.7, RailHV, false,
+		},
+		{
+			"Auto both connected, LV matches shared chemistry",
+			Battery{Rail: RailAuto, Chemistry: ChemistryLiIon, HVCellCount: 4, LVCellCount: 1},
+			9.0, 3.7, RailLV, false,
+		},
+		{"Invalid rail", Battery{Rail: "middle"}, 12.5, 3.7, "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rail, err := tc.battery.SelectRail(tc.hv, tc.lv)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("Expected error, got rail '%s'", rail)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if rail != tc.expected {
+				t.Errorf("Expected rail '%s', got '%s'", tc.expected, rail)
+			}
+		})
+	}
+}
+
+func TestGetRailBatteryPack(t *testing.T) {
+	b := Battery{Chemistry: ChemistryLiIon, ManualCellCount: 3, LVChemistry: ChemistryLiFePO4, LVCellCount: 1}
+
+	pack, err := b.GetRailBatteryPack(RailHV, 11.1)
+	if err != nil {
+		t.Fatalf("Failed to get battery pack: %v", err)
+	}
+	if pack.Type.Chemistry != ChemistryLiIon || pack.CellCount != 3 {
+		t.Errorf("Expected 3 cell li-ion pack for HV rail, got %d cell %s", pack.CellCount, pack.Type.Chemistry)
+	}
+
+	pack, err = b.GetRailBatteryPack(RailLV, 3.2)
+	if err != nil {
+		t.Fatalf("Failed to get battery pack: %v", err)
+	}
+	if pack.Type.Chemistry != ChemistryLiFePO4 || pack.CellCount != 1 {
+		t.Errorf("Expected 1 cell lifepo4 pack for LV rail, got %d cell %s", pack.CellCount, pack.Type.Chemistry)
+	}
+
+	if _, err := b.GetRailBatteryPack(RailAuto, 3.2); err == nil {
+		t.Error("Expected error for auto rail, got nil")
+	}
+
+	// A rail without a cell count detects it from the rail voltage, not the saved pack count.
+	b = Battery{Chemistry: ChemistryLiIon, DetectedCellCount: 3, LVChemistry: ChemistryLiFePO4}
+	pack, err = b.GetRailBatteryPack(RailLV, 3.2)
+	if err != nil {
+		t.Fatalf("Failed to get battery pack: %v", err)
+	}
+	if pack.Type.Chemistry != ChemistryLiFePO4 || pack.CellCount != 1 {
+		t.Errorf("Expected 1 cell lifepo4 pack for LV rail, got %d cell %s", pack.CellCount, pack.Type.Chemistry)
+	}
+}
+
+func TestValidateBatteryRail(t *testing.T) {
+	valid := []map[string]any{
+		{"rail": "hv"},
+		{"rail": "lv", "lv-chemistry": "lipo", "lv-cell-count": 1},
+		{"rail": "auto", "hv-chemistry": "lead-acid", "hv-cell-count": 6},
+	}
+	for _, m := range valid {
+		if err := batteryValidateFunc(m); err != nil {
+			t.Errorf("Expected %v to be valid, got %v", m, err)
+		}
+	}
+
+	invalid := []map[string]any{
+		{"rail": "HV"},
+		{"rail": "both"},
+		{"hv-chemistry": "nicad"},
+		{"lv-cell-count": 25},
+		{"hv-cell-count": -1},
+	}
+	for _, m := range invalid {
+		if err := batteryValidateFunc(m); err == nil {
+			t.Errorf("Expected %v to be invalid", m)
+		}
+	}
+}
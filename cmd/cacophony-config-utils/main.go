@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"os"
 
+	batterycalibrate "github.com/TheCacophonyProject/go-config/internal/battery-calibrate"
 	cacophonyconfig "github.com/TheCacophonyProject/go-config/internal/cacophony-config"
 	cacophonyconfigsync "github.com/TheCacophonyProject/go-config/internal/cacophony-config-sync"
+	findmodem "github.com/TheCacophonyProject/go-config/internal/find-modem"
+	thermalprofile "github.com/TheCacophonyProject/go-config/internal/thermal-profile"
 	"github.com/TheCacophonyProject/go-utils/logging"
 )
 
@@ -34,6 +37,12 @@ func runMain() error {
 		err = cacophonyconfigsync.Run(args, version)
 	case "config":
 		err = cacophonyconfig.Run(args, version)
+	case "battery-calibrate":
+		err = batterycalibrate.Run(args, version)
+	case "find-modem":
+		err = findmodem.Run(args, version)
+	case "thermal-profile":
+		err = thermalprofile.Run(args, version)
 	default:
 		err = fmt.Errorf("unknown subcommand: %s", sub)
 	}
@@ -0,0 +1,113 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed species.txt
+var speciesVocabulary string
+
+var (
+	speciesMu      sync.RWMutex
+	speciesAliases = map[string]string{} // Alias or canonical name to canonical name
+)
+
+func init() {
+	scanner := bufio.NewScanner(strings.NewReader(speciesVocabulary))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names := strings.Split(line, ",")
+		if err := RegisterSpecies(names[0], names[1:]...); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterSpecies adds a species and its aliases to the vocabulary so it can
+// be used in the trap and protect species of the comms section.
+func RegisterSpecies(name string, aliases ...string) error {
+	name = cleanSpeciesName(name)
+	if name == "" {
+		return fmt.Errorf("species name can't be empty")
+	}
+
+	speciesMu.Lock()
+	defer speciesMu.Unlock()
+	for _, alias := range append([]string{name}, aliases...) {
+		alias = cleanSpeciesName(alias)
+		if canonical, ok := speciesAliases[alias]; ok && canonical != name {
+			return fmt.Errorf("species '%s' is already an alias of '%s'", alias, canonical)
+		}
+		speciesAliases[alias] = name
+	}
+	return nil
+}
+
+// NormaliseSpecies returns the canonical name for a species or alias, and
+// false if it isn't in the vocabulary.
+func NormaliseSpecies(name string) (string, bool) {
+	speciesMu.RLock()
+	defer speciesMu.RUnlock()
+	canonical, ok := speciesAliases[cleanSpeciesName(name)]
+	return canonical, ok
+}
+
+// KnownSpecies returns the sorted canonical names of all species in the vocabulary.
+func KnownSpecies() []string {
+	speciesMu.RLock()
+	defer speciesMu.RUnlock()
+	species := []string{}
+	for alias, canonical := range speciesAliases {
+		if alias == canonical {
+			species = append(species, canonical)
+		}
+	}
+	sort.Strings(species)
+	return species
+}
+
+func cleanSpeciesName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "-", "_", "-").Replace(name)
+}
+
+// normaliseSpeciesMap returns the species map with canonical names, erroring on
+// unknown species or species that are given more than once.
+func normaliseSpeciesMap(species map[string]int32) (map[string]int32, error) {
+	normalised := make(map[string]int32, len(species))
+	for name, confidence := range species {
+		canonical, ok := NormaliseSpecies(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown species '%s'", name)
+		}
+		if _, ok := normalised[canonical]; ok {
+			return nil, fmt.Errorf("species '%s' is given more than once", canonical)
+		}
+		normalised[canonical] = confidence
+	}
+	return normalised, nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormaliseSpecies(t *testing.T) {
+	for name, expected := range map[string]string{
+		"possum":           "possum",
+		" Possum ":         "possum",
+		"Brushtail Possum": "possum",
+		"stoat":            "mustelid",
+		"false_positive":   "false-positive",
+	} {
+		species, ok := NormaliseSpecies(name)
+		require.True(t, ok, name)
+		require.Equal(t, expected, species, name)
+	}
+	_, ok := NormaliseSpecies("posum")
+	require.False(t, ok)
+
+	require.Contains(t, KnownSpecies(), "kiwi")
+	require.NotContains(t, KnownSpecies(), "stoat")
+}
+
+func TestRegisterSpecies(t *testing.T) {
+	defer func() {
+		speciesMu.Lock()
+		delete(speciesAliases, "takahe")
+		delete(speciesAliases, "notornis")
+		speciesMu.Unlock()
+	}()
+
+	require.NoError(t, RegisterSpecies("Takahe", "notornis"))
+	species, ok := NormaliseSpecies("Notornis")
+	require.True(t, ok)
+	require.Equal(t, "takahe", species)
+	require.Contains(t, KnownSpecies(), "takahe")
+
+	require.Error(t, RegisterSpecies("rodent", "stoat"))
+	require.Error(t, RegisterSpecies(" "))
+}
+
+func TestCommsValidation(t *testing.T) {
+	valid := func() Comms {
+		c := DefaultComms()
+		c.CommsOut = CommsOutUART
+		c.PowerOutput = PowerOutputOn
+		c.TrapSpecies = map[string]int32{"possum": 80, "Rat": 70}
+		c.ProtectSpecies = map[string]int32{"kiwi": 50}
+		return c
+	}
+	require.NoError(t, commsValidateFunc(valid()))
+	require.NoError(t, commsValidateFunc(DefaultComms()))
+
+	for name, modify := range map[string]func(c *Comms){
+		"comms out":            func(c *Comms) { c.CommsOut = "serial" },
+		"power output":         func(c *Comms) { c.PowerOutput = "sometimes" },
+		"no power up duration": func(c *Comms) { c.PowerOutput = PowerOutputCommsOnly },
+		"unknown species":      func(c *Comms) { c.TrapSpecies["posum"] = 80 },
+		"duplicate species":    func(c *Comms) { c.TrapSpecies["mouse"] = 80 },
+		"trap confidence":      func(c *Comms) { c.TrapSpecies["cat"] = 101 },
+		"protect confidence":   func(c *Comms) { c.ProtectSpecies["penguin"] = -1 },
+		"trapped and protected": func(c *Comms) {
+			c.ProtectSpecies["brushtail possum"] = 90
+		},
+	} {
+		c := valid()
+		modify(&c)
+		require.Error(t, commsValidateFunc(c), name)
+	}
+
+	c := valid()
+	c.PowerOutput = PowerOutputCommsOnly
+	c.PowerUpDuration = 10 * time.Second
+	require.NoError(t, commsValidateFunc(c))
+
+	require.NoError(t, commsValidateFunc(map[string]any{
+		"power-output":      "comms-only",
+		"power-up-duration": "10s",
+	}))
+}
+
+func TestCommsSpeciesNormalised(t *testing.T) {
+	defer newFs(t, "")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	require.NoError(t, conf.SetFromMap(CommsKey, map[string]any{
+		"trap-species": map[string]any{"Stoat": 80, "rat": 60},
+	}, false))
+	require.Error(t, conf.SetFromMap(CommsKey, map[string]any{
+		"trap-species": map[string]any{"posum": 80},
+	}, false))
+	// Aliases of the same species would otherwise be merged, with either one's
+	// confidence kept depending on map order.
+	require.Error(t, conf.SetFromMap(CommsKey, map[string]any{
+		"trap-species": map[string]any{"rat": 60, "mouse": 90},
+	}, false))
+
+	var c Comms
+	require.NoError(t, conf.Unmarshal(CommsKey, &c))
+	require.Equal(t, map[string]int32{"mustelid": 80, "rodent": 60}, c.TrapSpecies)
+}
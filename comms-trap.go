@@ -0,0 +1,161 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// TrapState is whether the trap should be active or not
+type TrapState string
+
+const (
+	TrapActive   TrapState = "active"
+	TrapInactive TrapState = "inactive"
+)
+
+// Classification is a species classification from the classifier
+type Classification struct {
+	Time       time.Time
+	Species    string
+	Confidence int32 // 0 to 100
+}
+
+// TrapController turns species classifications into trap state using the
+// trap and protect settings of the comms section.
+//
+// Seeing a trap species keeps the trap active for TrapDuration and seeing a
+// protect species keeps it inactive for ProtectDuration. Protecting takes
+// precedence, so while a protect species has been seen recently the trap stays
+// inactive even if a trap species is also seen. Once both have expired the
+// trap goes back to TrapEnabledByDefault.
+type TrapController struct {
+	comms        Comms
+	clock        func() time.Time
+	trapUntil    time.Time
+	protectUntil time.Time
+	lastState    TrapState
+	mu           sync.Mutex
+}
+
+// NewTrapController creates a TrapController from the comms section. The clock
+// is used to find the current state, time.Now is used if it is nil.
+func NewTrapController(comms Comms, clock func() time.Time) *TrapController {
+	if clock == nil {
+		clock = time.Now
+	}
+	tc := &TrapController{
+		comms: comms,
+		clock: clock,
+	}
+	tc.lastState = tc.stateAt(clock())
+	return tc
+}
+
+// AddClassification adds a classification and returns the resulting trap
+// state and if it changed since it was last returned.
+func (tc *TrapController) AddClassification(c Classification) (TrapState, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if threshold, ok := lookupSpecies(tc.comms.ProtectSpecies, c.Species); ok && c.Confidence >= threshold {
+		tc.protectUntil = laterTime(tc.protectUntil, c.Time.Add(tc.comms.ProtectDuration))
+	}
+	if threshold, ok := lookupSpecies(tc.comms.TrapSpecies, c.Species); ok && c.Confidence >= threshold {
+		tc.trapUntil = laterTime(tc.trapUntil, c.Time.Add(tc.comms.TrapDuration))
+	}
+	return tc.update()
+}
+
+// Update returns the trap state at the current time and if it changed since it was last returned.
+func (tc *TrapController) Update() (TrapState, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.update()
+}
+
+func (tc *TrapController) update() (TrapState, bool) {
+	state := tc.stateAt(tc.clock())
+	changed := state != tc.lastState
+	tc.lastState = state
+	return state, changed
+}
+
+// State returns the trap state at the current time.
+func (tc *TrapController) State() TrapState {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.stateAt(tc.clock())
+}
+
+// NextChange returns when the trap state could next change without any new
+// classifications, or the zero time if it won't.
+func (tc *TrapController) NextChange() time.Time {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	t := tc.clock()
+	switch {
+	case t.Before(tc.protectUntil):
+		return tc.protectUntil
+	case t.Before(tc.trapUntil):
+		return tc.trapUntil
+	default:
+		return time.Time{}
+	}
+}
+
+func (tc *TrapController) stateAt(t time.Time) TrapState {
+	switch {
+	case t.Before(tc.protectUntil):
+		return TrapInactive
+	case t.Before(tc.trapUntil):
+		return TrapActive
+	case tc.comms.TrapEnabledByDefault:
+		return TrapActive
+	default:
+		return TrapInactive
+	}
+}
+
+// lookupSpecies finds the confidence threshold for a species, by its canonical
+// name if it is in the vocabulary or otherwise ignoring case.
+func lookupSpecies(species map[string]int32, name string) (int32, bool) {
+	if threshold, ok := species[name]; ok {
+		return threshold, true
+	}
+	if canonical, ok := NormaliseSpecies(name); ok {
+		if threshold, ok := species[canonical]; ok {
+			return threshold, true
+		}
+	}
+	for s, threshold := range species {
+		if strings.EqualFold(s, name) {
+			return threshold, true
+		}
+	}
+	return 0, false
+}
+
+func laterTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestTrapController(enabledByDefault bool) (*TrapController, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	comms := DefaultComms()
+	comms.TrapEnabledByDefault = enabledByDefault
+	comms.TrapSpecies = map[string]int32{"possum": 80, "cat": 70}
+	comms.ProtectSpecies = map[string]int32{"kiwi": 50}
+	comms.TrapDuration = 5 * time.Minute
+	comms.ProtectDuration = 10 * time.Minute
+	return NewTrapController(comms, clock.now), clock
+}
+
+func TestTrapControllerDefaultState(t *testing.T) {
+	tc, _ := newTestTrapController(false)
+	require.Equal(t, TrapInactive, tc.State())
+	state, changed := tc.Update()
+	require.Equal(t, TrapInactive, state)
+	require.False(t, changed)
+	require.True(t, tc.NextChange().IsZero())
+
+	tc, _ = newTestTrapController(true)
+	require.Equal(t, TrapActive, tc.State())
+}
+
+func TestTrapControllerTrapSpecies(t *testing.T) {
+	tc, clock := newTestTrapController(false)
+
+	// Below the confidence threshold
+	state, changed := tc.AddClassification(Classification{Time: clock.now(), Species: "possum", Confidence: 79})
+	require.Equal(t, TrapInactive, state)
+	require.False(t, changed)
+
+	// Not a trap species
+	state, _ = tc.AddClassification(Classification{Time: clock.now(), Species: "rat", Confidence: 100})
+	require.Equal(t, TrapInactive, state)
+
+	state, changed = tc.AddClassification(Classification{Time: clock.now(), Species: "Possum", Confidence: 80})
+	require.Equal(t, TrapActive, state)
+	require.True(t, changed)
+	require.Equal(t, clock.now().Add(5*time.Minute), tc.NextChange())
+
+	clock.advance(4 * time.Minute)
+	state, changed = tc.Update()
+	require.Equal(t, TrapActive, state)
+	require.False(t, changed)
+
+	// Seeing it again extends the trap duration.
+	tc.AddClassification(Classification{Time: clock.now(), Species: "cat", Confidence: 90})
+	clock.advance(4 * time.Minute)
+	require.Equal(t, TrapActive, tc.State())
+
+	clock.advance(time.Minute)
+	state, changed = tc.Update()
+	require.Equal(t, TrapInactive, state)
+	require.True(t, changed)
+	require.True(t, tc.NextChange().IsZero())
+}
+
+func TestTrapControllerProtectOverridesTrap(t *testing.T) {
+	tc, clock := newTestTrapController(true)
+
+	tc.AddClassification(Classification{Time: clock.now(), Species: "possum", Confidence: 95})
+	require.Equal(t, TrapActive, tc.State())
+
+	clock.advance(time.Minute)
+	state, changed := tc.AddClassification(Classification{Time: clock.now(), Species: "kiwi", Confidence: 60})
+	require.Equal(t, TrapInactive, state)
+	require.True(t, changed)
+
+	// A trap species seen while protecting doesn't activate the trap.
+	clock.advance(time.Minute)
+	state, _ = tc.AddClassification(Classification{Time: clock.now(), Species: "possum", Confidence: 95})
+	require.Equal(t, TrapInactive, state)
+	require.Equal(t, clock.now().Add(9*time.Minute), tc.NextChange())
+
+	// By the time protecting expires, 10 minutes after the kiwi, the second
+	// possum has also expired so the trap goes back to the default.
+	clock.advance(9 * time.Minute)
+	state, changed = tc.Update()
+	require.Equal(t, TrapActive, state)
+	require.True(t, changed)
+	require.True(t, tc.NextChange().IsZero())
+}
+
+func TestTrapControllerProtectExpiresIntoTrap(t *testing.T) {
+	tc, clock := newTestTrapController(false)
+	tc.comms.TrapDuration = 30 * time.Minute
+
+	tc.AddClassification(Classification{Time: clock.now(), Species: "kiwi", Confidence: 60})
+	tc.AddClassification(Classification{Time: clock.now(), Species: "possum", Confidence: 95})
+	require.Equal(t, TrapInactive, tc.State())
+	require.Equal(t, clock.now().Add(10*time.Minute), tc.NextChange())
+
+	clock.advance(10 * time.Minute)
+	state, changed := tc.Update()
+	require.Equal(t, TrapActive, state)
+	require.True(t, changed)
+	require.Equal(t, clock.now().Add(20*time.Minute), tc.NextChange())
+
+	clock.advance(20 * time.Minute)
+	require.Equal(t, TrapInactive, tc.State())
+}
+
+func TestTrapControllerLateClassification(t *testing.T) {
+	tc, clock := newTestTrapController(false)
+
+	// A classification that is already older than the trap duration has no effect.
+	state, changed := tc.AddClassification(Classification{Time: clock.now().Add(-6 * time.Minute), Species: "cat", Confidence: 90})
+	require.Equal(t, TrapInactive, state)
+	require.False(t, changed)
+
+	tc.AddClassification(Classification{Time: clock.now().Add(-3 * time.Minute), Species: "cat", Confidence: 90})
+	require.Equal(t, TrapActive, tc.State())
+	require.Equal(t, clock.now().Add(2*time.Minute), tc.NextChange())
+}
@@ -16,15 +16,27 @@
 
 package config
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 const CommsKey = "comms"
 
+const (
+	CommsOutUART    = "uart"
+	CommsOutHighLow = "high-low"
+
+	PowerOutputOn        = "on"
+	PowerOutputOff       = "off"
+	PowerOutputCommsOnly = "comms-only"
+)
+
 func init() {
 	allSections[CommsKey] = section{
 		key:         CommsKey,
 		mapToStruct: commsMapToStruct,
-		validate:    noValidateFunc,
+		validate:    commsValidateFunc,
 		defaultValue: func() interface{} {
 			return DefaultComms()
 		},
@@ -37,7 +49,7 @@ func init() {
 type Comms struct {
 	Enable               bool   `mapstructure:"enable"`
 	TrapEnabledByDefault bool   `mapstructure:"trap-enabled-by-default"` // If no animals are seen should the trap be enabled or not.
-	CommsOut             string `mapstructure:"comms-out"`               // "uart" or "high-low"
+	CommsOut             string `mapstructure:"comms-out"`               // "uart" or "high-low", the wire formats are in the commscodec package.
 	Bluetooth            bool   `mapstructure:"bluetooth"`               // Bluetooth can only be enabled if UART is not in use.
 
 	PowerOutput     string        `mapstructure:"power-output"`      // "on", "off", "comms-only"
@@ -64,5 +76,79 @@ func commsMapToStruct(m map[string]interface{}) (interface{}, error) {
 	if err := decodeStructFromMap(&s, m, nil); err != nil {
 		return nil, err
 	}
+	// Unknown species are kept as they are so an old config can still be read.
+	var err error
+	if s.TrapSpecies, err = canonicalSpeciesKeys(s.TrapSpecies); err != nil {
+		return nil, fmt.Errorf("invalid trap species: %w", err)
+	}
+	if s.ProtectSpecies, err = canonicalSpeciesKeys(s.ProtectSpecies); err != nil {
+		return nil, fmt.Errorf("invalid protect species: %w", err)
+	}
 	return s, nil
 }
+
+// canonicalSpeciesKeys renames aliases to their canonical species. It's an
+// error for more than one name to end up as the same species.
+func canonicalSpeciesKeys(species map[string]int32) (map[string]int32, error) {
+	if species == nil {
+		return nil, nil
+	}
+	m := make(map[string]int32, len(species))
+	for name, confidence := range species {
+		if canonical, ok := NormaliseSpecies(name); ok {
+			name = canonical
+		}
+		if _, ok := m[name]; ok {
+			return nil, fmt.Errorf("species '%s' is given more than once", name)
+		}
+		m[name] = confidence
+	}
+	return m, nil
+}
+
+func commsValidateFunc(comms any) error {
+	c, err := sectionStruct[Comms](CommsKey, comms)
+	if err != nil {
+		return err
+	}
+
+	switch c.CommsOut {
+	case "", CommsOutUART, CommsOutHighLow:
+	default:
+		return fmt.Errorf("comms out must be '%s' or '%s', got '%s'", CommsOutUART, CommsOutHighLow, c.CommsOut)
+	}
+
+	switch c.PowerOutput {
+	case "", PowerOutputOn, PowerOutputOff:
+	case PowerOutputCommsOnly:
+		if c.PowerUpDuration <= 0 {
+			return fmt.Errorf("power up duration must be set when power output is '%s'", PowerOutputCommsOnly)
+		}
+	default:
+		return fmt.Errorf("power output must be one of '%s', '%s' or '%s', got '%s'",
+			PowerOutputOn, PowerOutputOff, PowerOutputCommsOnly, c.PowerOutput)
+	}
+
+	trap, err := normaliseSpeciesMap(c.TrapSpecies)
+	if err != nil {
+		return fmt.Errorf("invalid trap species: %w", err)
+	}
+	protect, err := normaliseSpeciesMap(c.ProtectSpecies)
+	if err != nil {
+		return fmt.Errorf("invalid protect species: %w", err)
+	}
+	for species, confidence := range trap {
+		if confidence < 0 || confidence > 100 {
+			return fmt.Errorf("trap confidence for '%s' must be between 0 and 100, got %d", species, confidence)
+		}
+		if _, ok := protect[species]; ok {
+			return fmt.Errorf("species '%s' can't be both trapped and protected", species)
+		}
+	}
+	for species, confidence := range protect {
+		if confidence < 0 || confidence > 100 {
+			return fmt.Errorf("protect confidence for '%s' must be between 0 and 100, got %d", species, confidence)
+		}
+	}
+	return nil
+}
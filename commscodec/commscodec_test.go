@@ -0,0 +1,162 @@
+package commscodec
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	config "github.com/TheCacophonyProject/go-config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUARTRoundTrip(t *testing.T) {
+	m := Message{State: config.TrapActive, Species: "possum", Confidence: 92}
+	frame, err := EncodeUART(m)
+	require.NoError(t, err)
+	require.Equal(t, []byte{StartByte, Version, 0x01, 92, 6}, frame[:headerLength])
+	require.Len(t, frame, headerLength+6+1)
+
+	// Trailing bytes belong to the next frame.
+	decoded, n, err := DecodeUART(append(frame, StartByte))
+	require.NoError(t, err)
+	require.Equal(t, len(frame), n)
+	require.Equal(t, m, decoded)
+
+	for i := range frame {
+		_, _, err := DecodeUART(frame[:i])
+		require.Error(t, err)
+	}
+}
+
+func TestUARTErrors(t *testing.T) {
+	_, err := EncodeUART(Message{State: "maybe"})
+	require.Error(t, err)
+	_, err = EncodeUART(Message{State: config.TrapActive, Confidence: 101})
+	require.Error(t, err)
+	_, err = EncodeUART(Message{State: config.TrapActive, Species: "kiwi\n"})
+	require.Error(t, err)
+
+	frame, err := EncodeUART(Message{State: config.TrapInactive, Species: "kiwi", Confidence: 60})
+	require.NoError(t, err)
+
+	corrupt := bytes.Clone(frame)
+	corrupt[len(corrupt)-2] ^= 0x01
+	_, _, err = DecodeUART(corrupt)
+	require.ErrorIs(t, err, ErrChecksum)
+
+	_, _, err = DecodeUART(frame[:headerLength+1])
+	require.ErrorIs(t, err, ErrShortFrame)
+
+	_, _, err = DecodeUART(frame[1:])
+	require.ErrorIs(t, err, ErrStartByte)
+
+	corrupt = bytes.Clone(frame)
+	corrupt[1] = 2
+	_, _, err = DecodeUART(corrupt)
+	require.ErrorIs(t, err, ErrVersion)
+
+	// An impossible length is rejected without waiting for more data.
+	_, _, err = DecodeUART([]byte{StartByte, Version, 0, 0, MaxSpeciesLength + 1})
+	require.ErrorIs(t, err, ErrLength)
+}
+
+func TestCRC8(t *testing.T) {
+	// Standard check value for CRC-8 with polynomial 0x07.
+	require.Equal(t, byte(0xF4), crc8([]byte("123456789")))
+}
+
+func TestHighLow(t *testing.T) {
+	for _, state := range []config.TrapState{config.TrapActive, config.TrapInactive} {
+		pulses, err := EncodeHighLow(state)
+		require.NoError(t, err)
+		decoded, err := DecodeHighLow(pulses)
+		require.NoError(t, err)
+		require.Equal(t, state, decoded)
+	}
+
+	// Timing jitter and a long final low are accepted.
+	state, err := DecodeHighLow([]Pulse{
+		{High: true, Duration: 110 * time.Millisecond},
+		{High: false, Duration: 90 * time.Millisecond},
+		{High: true, Duration: 100 * time.Millisecond},
+		{High: false, Duration: time.Second},
+	})
+	require.NoError(t, err)
+	require.Equal(t, config.TrapActive, state)
+
+	for _, pulses := range [][]Pulse{
+		nil,
+		{{High: true, Duration: PulseWidth}},
+		{{High: false, Duration: PulseWidth}, {High: true, Duration: PulseWidth}},
+		{{High: true, Duration: 50 * time.Millisecond}, {High: false, Duration: PulseWidth}},
+		{{High: true, Duration: time.Second}, {High: false, Duration: PulseWidth}},
+	} {
+		_, err := DecodeHighLow(pulses)
+		require.ErrorIs(t, err, ErrPulses)
+	}
+
+	_, err = EncodeHighLow("maybe")
+	require.Error(t, err)
+}
+
+func FuzzDecodeUART(f *testing.F) {
+	for _, m := range []Message{
+		{State: config.TrapActive, Species: "possum", Confidence: 80},
+		{State: config.TrapInactive},
+	} {
+		frame, err := EncodeUART(m)
+		require.NoError(f, err)
+		f.Add(frame)
+	}
+	f.Add([]byte{StartByte, Version, 0xFF, 0xFF, 0xFF})
+	f.Add([]byte{StartByte, Version, 0x00, 0x00, MaxSpeciesLength + 1})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, n, err := DecodeUART(data)
+		if err != nil {
+			return
+		}
+		frame, err := EncodeUART(m)
+		require.NoError(t, err)
+		require.Equal(t, data[:n], frame)
+	})
+}
+
+func FuzzUARTRoundTrip(f *testing.F) {
+	f.Add(true, "possum", uint8(80))
+	f.Add(false, "", uint8(0))
+
+	f.Fuzz(func(t *testing.T, active bool, species string, confidence uint8) {
+		m := Message{State: config.TrapInactive, Species: species, Confidence: confidence}
+		if active {
+			m.State = config.TrapActive
+		}
+		frame, err := EncodeUART(m)
+		if err != nil {
+			return
+		}
+		decoded, n, err := DecodeUART(frame)
+		require.NoError(t, err)
+		require.Equal(t, len(frame), n)
+		require.Equal(t, m, decoded)
+	})
+}
+
+func FuzzDecodeHighLow(f *testing.F) {
+	f.Add(true, int64(PulseWidth), int64(PulseWidth), int64(PulseWidth), int64(PulseWidth))
+
+	f.Fuzz(func(t *testing.T, firstHigh bool, d1, d2, d3, d4 int64) {
+		pulses := []Pulse{
+			{High: firstHigh, Duration: time.Duration(d1)},
+			{High: !firstHigh, Duration: time.Duration(d2)},
+			{High: firstHigh, Duration: time.Duration(d3)},
+			{High: !firstHigh, Duration: time.Duration(d4)},
+		}
+		state, err := DecodeHighLow(pulses)
+		if err != nil {
+			return
+		}
+		require.Equal(t, config.TrapActive, state)
+		require.True(t, firstHigh)
+	})
+}
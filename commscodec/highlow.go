@@ -0,0 +1,89 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package commscodec
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	config "github.com/TheCacophonyProject/go-config"
+)
+
+// The high-low output can only carry the trap state. It is sent as a train of
+// PulseWidth high pulses, each followed by PulseWidth low, with two pulses
+// for an active trap and one for an inactive trap.
+const (
+	PulseWidth     = 100 * time.Millisecond
+	PulseTolerance = 25 * time.Millisecond
+
+	activePulses   = 2
+	inactivePulses = 1
+)
+
+var ErrPulses = errors.New("invalid pulse train")
+
+// Pulse is a period of time the output is held at a level.
+type Pulse struct {
+	High     bool
+	Duration time.Duration
+}
+
+// EncodeHighLow returns the pulse train for the trap state.
+func EncodeHighLow(state config.TrapState) ([]Pulse, error) {
+	var count int
+	switch state {
+	case config.TrapActive:
+		count = activePulses
+	case config.TrapInactive:
+		count = inactivePulses
+	default:
+		return nil, fmt.Errorf("unknown trap state '%s'", state)
+	}
+	pulses := make([]Pulse, 0, 2*count)
+	for range count {
+		pulses = append(pulses, Pulse{High: true, Duration: PulseWidth}, Pulse{High: false, Duration: PulseWidth})
+	}
+	return pulses, nil
+}
+
+// DecodeHighLow returns the trap state for a pulse train. Each pulse must be
+// within PulseTolerance of PulseWidth, except the final low which can be
+// longer as the line stays low until the next train.
+func DecodeHighLow(pulses []Pulse) (config.TrapState, error) {
+	if len(pulses)%2 != 0 {
+		return "", fmt.Errorf("%w: expected pairs of high and low pulses, got %d pulses", ErrPulses, len(pulses))
+	}
+	for i, p := range pulses {
+		if p.High != (i%2 == 0) {
+			return "", fmt.Errorf("%w: pulse %d has the wrong level", ErrPulses, i)
+		}
+		tooShort := p.Duration < PulseWidth-PulseTolerance
+		tooLong := p.Duration > PulseWidth+PulseTolerance && i != len(pulses)-1
+		if tooShort || tooLong {
+			return "", fmt.Errorf("%w: pulse %d is %s, expected %s", ErrPulses, i, p.Duration, PulseWidth)
+		}
+	}
+	switch len(pulses) / 2 {
+	case activePulses:
+		return config.TrapActive, nil
+	case inactivePulses:
+		return config.TrapInactive, nil
+	default:
+		return "", fmt.Errorf("%w: unexpected number of pulses %d", ErrPulses, len(pulses)/2)
+	}
+}
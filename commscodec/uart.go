@@ -0,0 +1,151 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package commscodec defines the wire formats used to send trap and protect
+// signals to a trap, as selected by the comms-out setting of the comms section.
+//
+// A UART frame is laid out as:
+//
+//	byte 0       StartByte (0xA5)
+//	byte 1       Version (1)
+//	byte 2       Flags, bit 0 set when the trap should be active
+//	byte 3       Confidence, 0 to 100
+//	byte 4       Species length n, 0 to MaxSpeciesLength
+//	byte 5..4+n  Species, printable ASCII
+//	byte 5+n     CRC-8 (polynomial 0x07, initial value 0) of bytes 1 to 4+n
+package commscodec
+
+import (
+	"errors"
+	"fmt"
+
+	config "github.com/TheCacophonyProject/go-config"
+)
+
+const (
+	StartByte        = 0xA5
+	Version          = 1
+	MaxSpeciesLength = 32
+
+	flagTrapActive = 0x01
+	headerLength   = 5
+)
+
+var (
+	ErrShortFrame = errors.New("frame is too short")
+	ErrStartByte  = errors.New("frame doesn't begin with the start byte")
+	ErrVersion    = errors.New("unsupported frame version")
+	ErrChecksum   = errors.New("frame checksum doesn't match")
+	ErrLength     = errors.New("frame species length is too long")
+)
+
+// Message is a trap signal sent to a trap.
+type Message struct {
+	State      config.TrapState
+	Species    string // Species that caused the state, empty if it is the default state
+	Confidence uint8  // 0 to 100
+}
+
+func (m Message) validate() error {
+	if m.State != config.TrapActive && m.State != config.TrapInactive {
+		return fmt.Errorf("unknown trap state '%s'", m.State)
+	}
+	if m.Confidence > 100 {
+		return fmt.Errorf("confidence must be between 0 and 100, got %d", m.Confidence)
+	}
+	if len(m.Species) > MaxSpeciesLength {
+		return fmt.Errorf("species can be at most %d bytes, got %d", MaxSpeciesLength, len(m.Species))
+	}
+	for i := 0; i < len(m.Species); i++ {
+		if m.Species[i] < 0x20 || m.Species[i] > 0x7E {
+			return fmt.Errorf("species must be printable ASCII, got '%q'", m.Species)
+		}
+	}
+	return nil
+}
+
+// EncodeUART returns the UART frame for the message.
+func EncodeUART(m Message) ([]byte, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	frame := make([]byte, 0, headerLength+len(m.Species)+1)
+	var flags byte
+	if m.State == config.TrapActive {
+		flags |= flagTrapActive
+	}
+	frame = append(frame, StartByte, Version, flags, m.Confidence, byte(len(m.Species)))
+	frame = append(frame, m.Species...)
+	return append(frame, crc8(frame[1:])), nil
+}
+
+// DecodeUART decodes the frame at the start of data, returning the message and
+// the number of bytes used. ErrShortFrame is returned if data holds only part
+// of a frame, so more data can be read before trying again.
+func DecodeUART(data []byte) (Message, int, error) {
+	if len(data) < headerLength {
+		return Message{}, 0, ErrShortFrame
+	}
+	if data[0] != StartByte {
+		return Message{}, 0, ErrStartByte
+	}
+	if data[1] != Version {
+		return Message{}, 0, fmt.Errorf("%w %d", ErrVersion, data[1])
+	}
+	// Checked before waiting for the rest of the frame, which would never be valid.
+	if data[4] > MaxSpeciesLength {
+		return Message{}, 0, fmt.Errorf("%w: %d bytes", ErrLength, data[4])
+	}
+	n := headerLength + int(data[4]) + 1
+	if len(data) < n {
+		return Message{}, 0, ErrShortFrame
+	}
+	if crc8(data[1:n-1]) != data[n-1] {
+		return Message{}, 0, ErrChecksum
+	}
+
+	m := Message{
+		State:      config.TrapInactive,
+		Confidence: data[3],
+		Species:    string(data[headerLength : n-1]),
+	}
+	if data[2]&flagTrapActive != 0 {
+		m.State = config.TrapActive
+	}
+	if data[2]&^flagTrapActive != 0 {
+		return Message{}, 0, fmt.Errorf("unknown flags 0x%02x", data[2])
+	}
+	if err := m.validate(); err != nil {
+		return Message{}, 0, err
+	}
+	return m, n, nil
+}
+
+// crc8 is CRC-8 with polynomial 0x07 and an initial value of 0.
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for range 8 {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
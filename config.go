@@ -21,6 +21,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path"
 	"reflect"
 	"strings"
@@ -42,10 +43,13 @@ type ConfigArgs struct {
 }
 
 type Config struct {
-	v         *viper.Viper
-	fileLock  *flock.Flock
-	AutoWrite bool
-	mu        sync.Mutex
+	v          *viper.Viper
+	secrets    *viper.Viper // The secrets section, kept in SecretsFileName
+	secretsErr error        // Why the secrets couldn't be read or decrypted
+	hardware   Hardware     // The hardware the defaults are given for
+	fileLock   *flock.Flock
+	AutoWrite  bool
+	mu         sync.Mutex
 }
 
 const (
@@ -56,11 +60,12 @@ const (
 )
 
 type section struct {
-	key          string
-	mapToStruct  func(map[string]interface{}) (interface{}, error)
-	validate     func(interface{}) error
-	defaultValue func() interface{}
-	pointerValue func() interface{}
+	key             string
+	mapToStruct     func(map[string]interface{}) (interface{}, error)
+	validate        func(interface{}) error
+	defaultValue    func() interface{}
+	hardwareDefault func(Hardware) interface{} // Defaults that depend on the hardware, used over defaultValue when set
+	pointerValue    func() interface{}
 }
 
 var (
@@ -93,6 +98,32 @@ func GetDefaults() map[string]interface{} {
 	return configDefaults
 }
 
+// GetDefaults returns the defaults for the hardware of the config.
+func (c *Config) GetDefaults() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	configDefaults := make(map[string]interface{})
+	for key := range allSections {
+		if key == SecretsKey {
+			continue // Skip secrets
+		}
+		configDefaults[key] = c.defaultValue(key)
+	}
+	return configDefaults
+}
+
+// defaultValue returns the default for the section on the config's hardware.
+func (c *Config) defaultValue(key string) interface{} {
+	section := allSections[key]
+	if section.hardwareDefault != nil {
+		return section.hardwareDefault(c.hardware)
+	}
+	if section.defaultValue == nil {
+		return nil
+	}
+	return section.defaultValue()
+}
+
 func GetAllSections() map[string]interface{} {
 	configValues := make(map[string]interface{})
 	for key, section := range allSections {
@@ -130,12 +161,11 @@ func New(dir string) (*Config, error) {
 	// TODO Take service name and restart service if config changes
 	configFile := path.Join(dir, ConfigFileName)
 	c := &Config{
-		v:         viper.New(),
+		v:         newViper(configFile),
+		secrets:   newViper(path.Join(dir, SecretsFileName)),
 		fileLock:  flock.New(lockFilePath(configFile)),
 		AutoWrite: true,
 	}
-	c.v.SetFs(fs)
-	c.v.SetConfigFile(configFile)
 	if err := c.readInConfig(); err != nil {
 		return nil, err
 	}
@@ -156,7 +186,14 @@ func (c *Config) readInConfig() error {
 		return err
 	}
 	defer c.fileLock.Unlock()
-	return c.v.ReadInConfig()
+	if err := c.v.ReadInConfig(); err != nil {
+		return err
+	}
+	c.readInSecrets()
+	if err := c.migrateSecrets(); err != nil {
+		return err
+	}
+	return c.updateHardware(HardwareKey)
 }
 
 func (c *Config) Unmarshal(key string, raw interface{}) error {
@@ -169,7 +206,18 @@ func (c *Config) Unmarshal(key string, raw interface{}) error {
 }
 
 func (c *Config) unmarshal(key string, raw interface{}) error {
-	return c.v.UnmarshalKey(key, raw)
+	if isSecretKey(key) && c.secretsErr != nil {
+		return c.secretsErr
+	}
+	// Viper's default hooks, plus thermal-motion thresholds with units from hand edited files.
+	hooks := []mapstructure.DecodeHookFunc{
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	}
+	if key == ThermalMotionKey {
+		hooks = append(hooks, ThresholdProfileFor(c.hardware.CameraModel).thresholdHook)
+	}
+	return c.viperFor(key).UnmarshalKey(key, raw, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(hooks...)))
 }
 
 // Set can only update one section at a time.
@@ -236,7 +284,11 @@ func (c *Config) setFromMap(sectionKey string, newConfig map[string]interface{},
 
 	// Convert to section for type conversion and other checks
 	section := allSections[sectionKey]
-	newStruct, err := section.mapToStruct(newConfig)
+	parsed, err := c.parseSectionMap(sectionKey, newConfig)
+	if err != nil {
+		return err
+	}
+	newStruct, err := section.mapToStruct(parsed)
 	if err != nil {
 		if force {
 			// If failed to convert new config map to a struct of that section then
@@ -294,11 +346,9 @@ func (c *Config) Reload() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	configFile := c.v.ConfigFileUsed()
-	// Need a new viper instance to clear old settings
-	c.v = viper.New()
-	c.v.SetFs(fs)
-	c.v.SetConfigFile(configFile)
+	// Need new viper instances to clear old settings
+	c.v = newViper(c.v.ConfigFileUsed())
+	c.secrets = newViper(c.secrets.ConfigFileUsed())
 	return c.readInConfig()
 }
 
@@ -327,32 +377,50 @@ func (c *Config) Unset(key string) error {
 }
 
 func (c *Config) unset(key string) error {
-	configMap := c.v.AllSettings()
 	path := strings.Split(key, ".")
-	deepestMap, err := deepSearch(configMap, path[0:len(path)-1])
+	v, err := withoutKey(c.viperFor(key), path)
 	if err != nil {
 		return err
 	}
+	v.Set(path[0]+".updated", now())
+	if isSecretKey(key) {
+		c.secrets = v
+	} else {
+		c.v = v
+	}
+	return c.updateHardware(key)
+}
+
+// withoutKey returns a copy of v with the key at path removed.
+func withoutKey(v *viper.Viper, path []string) (*viper.Viper, error) {
+	configMap := v.AllSettings()
+	deepestMap, err := deepSearch(configMap, path[0:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
 	delete(deepestMap, path[len(path)-1])
 	tomlTree, err := toml.TreeFromMap(configMap)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	configFile := c.v.ConfigFileUsed()
 	// Need a new viper instance to clear old settings
-	c.v = viper.New()
-	c.v.SetFs(fs)
-	c.v.SetConfigFile(configFile)
+	nv := newViper(v.ConfigFileUsed())
 	var buf bytes.Buffer
 	_, err = tomlTree.WriteTo(&buf)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := c.v.ReadConfig(bytes.NewReader(buf.Bytes())); err != nil {
-		return err
+	if err := nv.ReadConfig(bytes.NewReader(buf.Bytes())); err != nil {
+		return nil, err
 	}
-	c.v.Set(path[0]+".updated", now())
-	return nil
+	return nv, nil
+}
+
+func newViper(configFile string) *viper.Viper {
+	v := viper.New()
+	v.SetFs(fs)
+	v.SetConfigFile(configFile)
+	return v
 }
 
 var errNoFileLock = errors.New("failed to get lock on file")
@@ -401,20 +469,8 @@ func (c *Config) Write() error {
 }
 
 func (c *Config) write() error {
-	configMap := map[string]interface{}{}
-	for key := range allSections {
-		if key != SecretsKey {
-			configMap[key] = c.get(key)
-		}
-	}
-
 	if writeEvents {
-		event := eventclient.Event{
-			Timestamp: time.Now(),
-			Type:      "config",
-			Details:   configMap,
-		}
-		eventclient.AddEvent(event)
+		eventclient.AddEvent(c.configEvent())
 		eventclient.UploadEvents()
 
 	}
@@ -422,7 +478,30 @@ func (c *Config) write() error {
 		return err
 	}
 	defer c.fileLock.Unlock()
-	return c.v.WriteConfig()
+	if err := c.v.WriteConfig(); err != nil {
+		return err
+	}
+	// Secrets that couldn't be decrypted are left as they are.
+	if c.secretsErr != nil {
+		return nil
+	}
+	return c.writeSecrets()
+}
+
+// configEvent returns the event for the config being written, which never
+// has any secrets.
+func (c *Config) configEvent() eventclient.Event {
+	configMap := map[string]interface{}{}
+	for key := range allSections {
+		if key != SecretsKey {
+			configMap[key] = c.get(key)
+		}
+	}
+	return eventclient.Event{
+		Timestamp: time.Now(),
+		Type:      "config",
+		Details:   Redact(configMap),
+	}
 }
 
 func notSectionKeyError(key string) error {
@@ -437,10 +516,22 @@ func checkIfSectionKey(key string) bool {
 func (c *Config) validateAndSet(key string, value interface{}) error {
 	// Section will be the first part of the key
 	section := strings.Split(key, ".")[0]
+	if section == SecretsKey && c.secretsErr != nil {
+		return c.secretsErr
+	}
+	// Store values such as thresholds with units the same way setFromMap does.
+	if m, ok := value.(map[string]interface{}); ok && key == section {
+		parsed, err := c.parseSectionMap(section, m)
+		if err != nil {
+			return err
+		}
+		value = parsed
+	}
 	// Validate the section first.
-	if err := allSections[section].validate(value); err != nil {
+	if err := c.validateSection(section, value); err != nil {
 		return err
 	}
+	oldPassword, oldHash := c.passwordAndHash()
 
 	// if value is a map then remove all the keys in it
 	if m, ok := value.(map[string]interface{}); ok {
@@ -449,15 +540,83 @@ func (c *Config) validateAndSet(key string, value interface{}) error {
 		}
 	}
 
-	c.v.Set(key, value)
-	c.v.Set(section+".updated", now())
+	v := c.viperFor(key)
+	v.Set(key, value)
+	v.Set(section+".updated", now())
+	if section == SecretsKey {
+		if err := c.updatePasswordHash(oldPassword, oldHash); err != nil {
+			return err
+		}
+	}
+	return c.updateHardware(key)
+}
+
+// validateSection validates a value for the section. A map can be only part of
+// the section, so it is validated laid over the current values of the section,
+// which is how it will be read.
+func (c *Config) validateSection(key string, value interface{}) error {
+	if m, ok := value.(map[string]interface{}); ok {
+		merged, err := c.currentSectionWith(key, m)
+		if err != nil {
+			return err
+		}
+		if value, err = c.parseSectionMap(key, merged); err != nil {
+			return err
+		}
+	}
+	if err := allSections[key].validate(value); err != nil {
+		return err
+	}
+	if key == GPIOKey || key == CommsKey {
+		return c.checkGPIOConflicts(key, value)
+	}
 	return nil
 }
 
+// parseSectionMap converts values in the map for the section that depend on
+// the hardware, such as thermal-motion thresholds given with units.
+func (c *Config) parseSectionMap(key string, m map[string]interface{}) (map[string]interface{}, error) {
+	if key != ThermalMotionKey {
+		return m, nil
+	}
+	return ThresholdProfileFor(c.hardware.CameraModel).parseThresholds(m)
+}
+
+// currentSectionWith returns the section's defaults, overridden by what is in
+// the config, overridden by the values in m.
+func (c *Config) currentSectionWith(key string, m map[string]interface{}) (map[string]interface{}, error) {
+	merged := map[string]interface{}{}
+	if defaultValue := c.defaultValue(key); defaultValue != nil {
+		defaults, err := interfaceToMap(defaultValue)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range defaults {
+			merged[strings.ToLower(k)] = v
+		}
+	}
+	current, _ := c.viperFor(key).AllSettings()[key].(map[string]interface{})
+	t, ok := sectionType(key)
+	for k, v := range current {
+		// Skip "updated" and anything else the section doesn't have, such as
+		// from an old version, as they would fail to decode.
+		if ok && derefType(t).Kind() == reflect.Struct {
+			if _, ok := fieldByName(derefType(t), k); !ok {
+				continue
+			}
+		}
+		merged[strings.ToLower(k)] = v
+	}
+	for k, v := range m {
+		merged[strings.ToLower(k)] = v
+	}
+	return merged, nil
+}
+
 func (c *Config) Get(key string) interface{} {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return c.v.Get(key)
+	return c.get(key)
 }
 
 func (c *Config) SetMultipleSections(newConfig map[string]interface{}) error {
@@ -480,11 +639,10 @@ func (c *Config) SetMultipleSections(newConfig map[string]interface{}) error {
 func (c *Config) setMultipleSections(newConfig map[string]interface{}) error {
 	// Validate all sections before setting them.
 	for sectionKey, value := range newConfig {
-		section, ok := allSections[sectionKey]
-		if !ok {
+		if !checkIfSectionKey(sectionKey) {
 			return notSectionKeyError(sectionKey)
 		}
-		if err := section.validate(value); err != nil {
+		if err := c.validateSection(sectionKey, value); err != nil {
 			return err
 		}
 	}
@@ -499,7 +657,7 @@ func (c *Config) setMultipleSections(newConfig map[string]interface{}) error {
 }
 
 func (c *Config) get(key string) interface{} {
-	return c.v.Get(key)
+	return c.viperFor(key).Get(key)
 }
 
 func SetFs(f afero.Fs) {
@@ -510,6 +668,23 @@ func SetLockFilePath(f func(string) string) {
 	lockFilePath = f
 }
 
+// writeFileAtomic replaces the file with b. It is written to a temporary file
+// first so a power cut won't leave the file half written.
+func writeFileAtomic(file string, b []byte, perm os.FileMode) error {
+	if err := fs.MkdirAll(path.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmpPath := file + ".tmp"
+	// Remove any old temporary file as writing keeps its permissions.
+	if err := fs.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := afero.WriteFile(fs, tmpPath, b, perm); err != nil {
+		return err
+	}
+	return fs.Rename(tmpPath, file)
+}
+
 func decodeStructFromMap(s interface{}, m map[string]interface{}, decodeHook interface{}) error {
 	decoderConfig := mapstructure.DecoderConfig{
 		DecodeHook:       mapstructure.ComposeDecodeHookFunc(stringToDuration, stringToTime),
@@ -583,6 +758,21 @@ func copyAndInsensitiviseMap(m map[string]interface{}) map[string]interface{} {
 	return nm
 }
 
+// sectionStruct converts the value of a section to its struct. Maps are decoded
+// with the section's mapToStruct so its hooks apply, such as durations given as
+// strings, which ConvertToStruct doesn't handle.
+func sectionStruct[T any](key string, value interface{}) (T, error) {
+	if m, ok := value.(map[string]interface{}); ok {
+		s, err := allSections[key].mapToStruct(m)
+		if err != nil {
+			var out T
+			return out, err
+		}
+		value = s
+	}
+	return ConvertToStruct[T](value)
+}
+
 // ConvertToStruct converts an interface{} to the target struct type T.
 // Can input the struct itself, a pointer to the struct, or a map[string]interface{}
 func ConvertToStruct[T any](input interface{}) (T, error) {
@@ -109,6 +109,8 @@ func TestReadingConfigInDir(t *testing.T) {
 	secretsChanges := Secrets{}
 	secretsChanges.DevicePassword = "pass"
 	assert.NoError(t, conf.Unmarshal(SecretsKey, &secrets))
+	assert.True(t, secrets.VerifyPassword("pass"))
+	secretsChanges.DevicePasswordHash = secrets.DevicePasswordHash
 	assert.Equal(t, secretsChanges, secrets)
 
 	thermalMotion := DefaultThermalMotion("")
@@ -248,7 +250,7 @@ func TestFileLock(t *testing.T) {
 
 func TestSettingUpdated(t *testing.T) {
 	defer newFs(t, "")()
-	newNow()
+	newNow(t)
 	conf, err := New(DefaultConfigDir)
 	require.NoError(t, err)
 
@@ -261,7 +263,7 @@ func TestMapToLocation(t *testing.T) {
 	conf, err := New(DefaultConfigDir)
 	require.NoError(t, err)
 
-	newNow()
+	newNow(t)
 	locationMap := map[string]interface{}{
 		"latitude":  "27.123",
 		"timestamp": now().Format(TimeFormat),
@@ -351,7 +353,7 @@ func TestSettingMultipleSections(t *testing.T) {
 	defer newFs(t, "")()
 	conf, err := New(DefaultConfigDir)
 	require.NoError(t, err)
-	newNow()
+	newNow(t)
 	d := randomDevice()
 	w := randomWindows()
 	l := randomLocation()
@@ -388,7 +390,7 @@ func TestNotWritingZeroValues(t *testing.T) {
 	conf, err := New(DefaultConfigDir)
 	require.NoError(t, err)
 
-	newNow()
+	newNow(t)
 	locationMap := map[string]interface{}{
 		"lAtitUde": "28.283",
 	}
@@ -421,8 +423,8 @@ func TestMapToBattery(t *testing.T) {
 	defer newFs(t, "")()
 	conf, err := New(DefaultConfigDir)
 	require.NoError(t, err)
-	batteryMap := map[string]any{"chemistry": "lipo"}
-	batteryExpected := Battery{Chemistry: ChemistryLiPo}
+	batteryMap := map[string]any{"chemistry": "lipo", "rail": "lv", "lv-cell-count": "2"}
+	batteryExpected := Battery{Chemistry: ChemistryLiPo, Rail: RailLV, LVCellCount: 2}
 	checkWritingMap(t, BatteryKey, &Battery{}, &batteryExpected, batteryMap, conf)
 }
 
@@ -552,6 +554,11 @@ func checkWritingMap(
 		require.Equal(t, expectedBattery.MinimumVoltageDetection, battery.MinimumVoltageDetection)
 		require.Equal(t, expectedBattery.DepletionHistoryHours, battery.DepletionHistoryHours)
 		require.Equal(t, expectedBattery.DepletionWarningHours, battery.DepletionWarningHours)
+		require.Equal(t, expectedBattery.Rail, battery.Rail)
+		require.Equal(t, expectedBattery.HVChemistry, battery.HVChemistry)
+		require.Equal(t, expectedBattery.HVCellCount, battery.HVCellCount)
+		require.Equal(t, expectedBattery.LVChemistry, battery.LVChemistry)
+		require.Equal(t, expectedBattery.LVCellCount, battery.LVCellCount)
 		// Updated field is set automatically - just verify it's not nil
 		require.NotNil(t, battery.Updated)
 	} else {
@@ -569,7 +576,8 @@ func newFs(t *testing.T, configFile string) func() {
 	return cleanupFunc
 }
 
-func newNow() {
+func newNow(t *testing.T) {
+	t.Cleanup(func() { now = time.Now })
 	n := time.Now()
 	now = func() time.Time {
 		return n
@@ -641,7 +649,7 @@ func randomTestHosts() TestHosts {
 	return TestHosts{
 		URLs:         []string{randString(10), randString(20), randString(15)},
 		PingRetries:  int(randSrc.Int63()),
-		PingWaitTime: time.Duration(randSrc.Int63()) * time.Second,
+		PingWaitTime: time.Duration(randSrc.Int63()%3600+1) * time.Second,
 	}
 }
 
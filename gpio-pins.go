@@ -0,0 +1,120 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const BoardRaspberryPi = "raspberry-pi"
+
+// Pin is a GPIO pin on a board.
+type Pin struct {
+	BCM    int // Broadcom GPIO number
+	Header int // Physical pin number on the 40 pin header
+}
+
+// Name returns the canonical name of the pin, e.g. "GPIO23".
+func (p Pin) Name() string {
+	return fmt.Sprintf("GPIO%d", p.BCM)
+}
+
+func (p Pin) String() string {
+	return fmt.Sprintf("%s (pin %d)", p.Name(), p.Header)
+}
+
+// boardPins is the layout of GPIO pins on a board.
+type boardPins struct {
+	headerByBCM map[int]int
+	aliases     map[string]int // Alternative names to BCM numbers
+	uartTx      map[int]bool   // Pins that can be a UART TX, the RX is the next pin
+}
+
+var boards = map[string]boardPins{
+	BoardRaspberryPi: {
+		headerByBCM: map[int]int{
+			0: 27, 1: 28, 2: 3, 3: 5, 4: 7, 5: 29, 6: 31, 7: 26, 8: 24, 9: 21,
+			10: 19, 11: 23, 12: 32, 13: 33, 14: 8, 15: 10, 16: 36, 17: 11, 18: 12, 19: 35,
+			20: 38, 21: 40, 22: 15, 23: 16, 24: 18, 25: 22, 26: 37, 27: 13,
+		},
+		aliases: map[string]int{
+			"SDA": 2, "SCL": 3,
+			"TXD": 14, "RXD": 15, "UART-TX": 14, "UART-RX": 15,
+			"MOSI": 10, "MISO": 9, "SCLK": 11, "CE0": 8, "CE1": 7,
+			"PCM-CLK": 18,
+		},
+		uartTx: map[int]bool{14: true, 0: true, 4: true, 8: true, 12: true},
+	},
+}
+
+// ParsePin finds a pin on the board by name. Pins can be given by GPIO or BCM
+// number ("GPIO23", "BCM23" or "23"), by header pin ("PIN16" or "P1-16"), or
+// by an alias such as "TXD" or "SDA". An empty board is a Raspberry Pi.
+func ParsePin(board, name string) (Pin, error) {
+	if board == "" {
+		board = BoardRaspberryPi
+	}
+	b, ok := boards[board]
+	if !ok {
+		return Pin{}, fmt.Errorf("unknown board '%s'", board)
+	}
+
+	n := strings.ToUpper(strings.TrimSpace(name))
+	if bcm, ok := b.aliases[n]; ok {
+		return Pin{BCM: bcm, Header: b.headerByBCM[bcm]}, nil
+	}
+	for _, prefix := range []string{"PIN", "P1-"} {
+		if rest, ok := strings.CutPrefix(n, prefix); ok {
+			header, err := strconv.Atoi(rest)
+			if err != nil {
+				break
+			}
+			for bcm, h := range b.headerByBCM {
+				if h == header {
+					return Pin{BCM: bcm, Header: h}, nil
+				}
+			}
+			return Pin{}, fmt.Errorf("header pin %d on '%s' isn't a GPIO pin", header, board)
+		}
+	}
+	for _, prefix := range []string{"GPIO", "BCM", ""} {
+		if rest, ok := strings.CutPrefix(n, prefix); ok {
+			bcm, err := strconv.Atoi(rest)
+			if err != nil {
+				continue
+			}
+			if header, ok := b.headerByBCM[bcm]; ok {
+				return Pin{BCM: bcm, Header: header}, nil
+			}
+			return Pin{}, fmt.Errorf("'%s' has no GPIO%d", board, bcm)
+		}
+	}
+	return Pin{}, fmt.Errorf("unknown GPIO pin '%s'", name)
+}
+
+// uartRxFor returns the UART RX pin that goes with a TX pin.
+func uartRxFor(board string, tx Pin) (Pin, error) {
+	if board == "" {
+		board = BoardRaspberryPi
+	}
+	if !boards[board].uartTx[tx.BCM] {
+		return Pin{}, fmt.Errorf("%s can't be used as a UART TX on '%s'", tx, board)
+	}
+	return ParsePin(board, strconv.Itoa(tx.BCM+1))
+}
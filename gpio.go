@@ -16,16 +16,28 @@
 
 package config
 
+import "fmt"
+
 const GPIOKey = "gpio"
 
+// GPIO roles, the keys of the pins in the gpio section
+const (
+	GPIOThermalCameraPower = "thermal-camera-power"
+	GPIOModemPower         = "modem-power"
+	GPIOUartTx             = "uart-tx"
+)
+
 func init() {
 	allSections[GPIOKey] = section{
 		key:         GPIOKey,
 		mapToStruct: gpioMapToStruct,
-		validate:    noValidateFunc,
+		validate:    gpioValidateFunc,
 		defaultValue: func() interface{} {
 			return DefaultGPIO()
 		},
+		hardwareDefault: func(h Hardware) interface{} {
+			return DefaultGPIOFor(h)
+		},
 		pointerValue: func() interface{} {
 			return &GPIO{}
 		},
@@ -36,14 +48,17 @@ type GPIO struct {
 	ThermalCameraPower string `mapstructure:"thermal-camera-power"`
 	ModemPower         string `mapstructure:"modem-power"`
 	UartTx             string `mapstructure:"uart-tx"`
+	Board              string `mapstructure:"board"` // Board the pins are named for, empty for a Raspberry Pi
 }
 
+// DefaultGPIO returns the pins of the default hardware.
 func DefaultGPIO() GPIO {
-	return GPIO{
-		ThermalCameraPower: "GPIO23",
-		ModemPower:         "GPIO22",
-		UartTx:             "GPIO14",
-	}
+	return DefaultGPIOFor(DefaultHardware())
+}
+
+// DefaultGPIOFor returns the pins of the hardware profile for the hardware.
+func DefaultGPIOFor(h Hardware) GPIO {
+	return hardwareProfileFor(h).GPIO
 }
 
 func gpioMapToStruct(m map[string]interface{}) (interface{}, error) {
@@ -53,3 +68,106 @@ func gpioMapToStruct(m map[string]interface{}) (interface{}, error) {
 	}
 	return s, nil
 }
+
+func (g GPIO) roles() []struct{ role, pin string } {
+	return []struct{ role, pin string }{
+		{GPIOThermalCameraPower, g.ThermalCameraPower},
+		{GPIOModemPower, g.ModemPower},
+		{GPIOUartTx, g.UartTx},
+	}
+}
+
+// Pin returns the pin used for a role, such as GPIOModemPower.
+func (g GPIO) Pin(role string) (Pin, error) {
+	for _, r := range g.roles() {
+		if r.role != role {
+			continue
+		}
+		if r.pin == "" {
+			return Pin{}, fmt.Errorf("no pin is set for '%s'", role)
+		}
+		return ParsePin(g.Board, r.pin)
+	}
+	return Pin{}, fmt.Errorf("unknown GPIO role '%s'", role)
+}
+
+// CheckConflicts checks that every pin exists and that no two roles share a
+// pin. When comms is sending over UART the RX pin that goes with the UART TX
+// pin is also in use.
+func (g GPIO) CheckConflicts(comms Comms) error {
+	used := map[int]string{}
+	use := func(role string, pin Pin) error {
+		if other, ok := used[pin.BCM]; ok {
+			return fmt.Errorf("'%s' and '%s' both use %s", other, role, pin)
+		}
+		used[pin.BCM] = role
+		return nil
+	}
+
+	for _, r := range g.roles() {
+		if r.pin == "" {
+			continue
+		}
+		pin, err := ParsePin(g.Board, r.pin)
+		if err != nil {
+			return fmt.Errorf("invalid pin for '%s': %w", r.role, err)
+		}
+		if err := use(r.role, pin); err != nil {
+			return err
+		}
+	}
+
+	if comms.Enable && comms.CommsOut == CommsOutUART {
+		tx, err := g.Pin(GPIOUartTx)
+		if err != nil {
+			return fmt.Errorf("comms out is UART: %w", err)
+		}
+		rx, err := uartRxFor(g.Board, tx)
+		if err != nil {
+			return err
+		}
+		if err := use("uart-rx", rx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func gpioValidateFunc(gpio any) error {
+	g, err := ConvertToStruct[GPIO](gpio)
+	if err != nil {
+		return err
+	}
+	if g.Board != "" {
+		if _, ok := boards[g.Board]; !ok {
+			return fmt.Errorf("unknown board '%s'", g.Board)
+		}
+	}
+	// Comms is in another section, the config checks the pins against it.
+	return g.CheckConflicts(Comms{})
+}
+
+// checkGPIOConflicts checks the pins against comms, which are in different
+// sections. The value is for the section being set and the other section is
+// taken from the config.
+func (c *Config) checkGPIOConflicts(key string, value interface{}) error {
+	gpio, comms := value, value
+	var err error
+	if key == GPIOKey {
+		comms, err = c.currentSectionWith(CommsKey, nil)
+	} else {
+		gpio, err = c.currentSectionWith(GPIOKey, nil)
+	}
+	if err != nil {
+		return err
+	}
+	g, err := sectionStruct[GPIO](GPIOKey, gpio)
+	if err != nil {
+		return err
+	}
+	cm, err := sectionStruct[Comms](CommsKey, comms)
+	if err != nil {
+		return err
+	}
+	return g.CheckConflicts(cm)
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePin(t *testing.T) {
+	for _, name := range []string{"GPIO23", "gpio23", " BCM23 ", "23", "PIN16", "p1-16"} {
+		pin, err := ParsePin("", name)
+		require.NoError(t, err, name)
+		require.Equal(t, Pin{BCM: 23, Header: 16}, pin, name)
+	}
+
+	pin, err := ParsePin(BoardRaspberryPi, "TXD")
+	require.NoError(t, err)
+	require.Equal(t, "GPIO14", pin.Name())
+	require.Equal(t, 8, pin.Header)
+
+	for _, name := range []string{"a gpio pin", "GPIO28", "PIN1", "PIN41", ""} {
+		_, err := ParsePin("", name)
+		require.Error(t, err, name)
+	}
+	_, err = ParsePin("arduino", "GPIO23")
+	require.Error(t, err)
+}
+
+func TestGPIOPin(t *testing.T) {
+	g := DefaultGPIO()
+	pin, err := g.Pin(GPIOModemPower)
+	require.NoError(t, err)
+	require.Equal(t, Pin{BCM: 22, Header: 15}, pin)
+
+	_, err = g.Pin("fan")
+	require.Error(t, err)
+
+	g.ModemPower = ""
+	_, err = g.Pin(GPIOModemPower)
+	require.Error(t, err)
+}
+
+func TestGPIOValidation(t *testing.T) {
+	require.NoError(t, gpioValidateFunc(DefaultGPIO()))
+	require.NoError(t, gpioValidateFunc(map[string]any{"modem-power": "PIN18"}))
+
+	require.Error(t, gpioValidateFunc(map[string]any{"thermal-camera-power": "a gpio pin"}))
+	require.Error(t, gpioValidateFunc(map[string]any{"board": "arduino"}))
+
+	// The same pin under different names
+	g := DefaultGPIO()
+	g.ModemPower = "PIN16"
+	require.Error(t, gpioValidateFunc(g))
+}
+
+func TestGPIOConflictsWithComms(t *testing.T) {
+	comms := DefaultComms()
+	comms.Enable = true
+	comms.CommsOut = CommsOutUART
+
+	g := DefaultGPIO()
+	require.NoError(t, g.CheckConflicts(comms))
+
+	// The UART RX pin is in use as well.
+	g.ModemPower = "RXD"
+	require.NoError(t, g.CheckConflicts(DefaultComms()))
+	require.Error(t, g.CheckConflicts(comms))
+
+	// Not a UART TX pin
+	g = DefaultGPIO()
+	g.UartTx = "GPIO24"
+	require.Error(t, g.CheckConflicts(comms))
+
+	g.UartTx = ""
+	require.Error(t, g.CheckConflicts(comms))
+	comms.CommsOut = CommsOutHighLow
+	require.NoError(t, g.CheckConflicts(comms))
+}
+
+func TestSetGPIOChecksConflicts(t *testing.T) {
+	defer newFs(t, "")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	// Partial maps are checked with the pins already in the config.
+	require.Error(t, conf.SetFromMap(GPIOKey, map[string]any{"modem-power": "GPIO23"}, false))
+	require.NoError(t, conf.SetFromMap(GPIOKey, map[string]any{"thermal-camera-power": "GPIO24"}, false))
+	require.NoError(t, conf.SetFromMap(GPIOKey, map[string]any{"modem-power": "GPIO23"}, false))
+
+	// The UART RX pin can't be used when comms is sending over UART, whichever is set last.
+	require.NoError(t, conf.SetFromMap(CommsKey, map[string]any{"enable": true, "comms-out": CommsOutUART}, false))
+	require.Error(t, conf.SetFromMap(GPIOKey, map[string]any{"modem-power": "RXD"}, false))
+
+	require.NoError(t, conf.SetFromMap(CommsKey, map[string]any{"comms-out": CommsOutHighLow}, false))
+	require.NoError(t, conf.SetFromMap(GPIOKey, map[string]any{"modem-power": "RXD"}, false))
+	require.Error(t, conf.SetFromMap(CommsKey, map[string]any{"comms-out": CommsOutUART}, false))
+}
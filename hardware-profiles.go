@@ -0,0 +1,100 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultBoardRevision is the board revision used when none is configured
+const DefaultBoardRevision = "classic"
+
+// HardwareProfile holds the defaults for a board revision.
+type HardwareProfile struct {
+	BoardRevision string
+	GPIO          GPIO
+	Lepton        Lepton
+}
+
+var (
+	hardwareMu       sync.RWMutex
+	hardwareProfiles = map[string]HardwareProfile{}
+)
+
+func init() {
+	if err := RegisterHardwareProfile(HardwareProfile{
+		BoardRevision: DefaultBoardRevision,
+		GPIO: GPIO{
+			ThermalCameraPower: "GPIO23",
+			ModemPower:         "GPIO22",
+			UartTx:             "GPIO14",
+		},
+		Lepton: Lepton{
+			SPISpeed:    20000000,
+			FrameOutput: "/var/run/lepton-frames",
+		},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterHardwareProfile adds or replaces the profile for a board revision.
+func RegisterHardwareProfile(p HardwareProfile) error {
+	if p.BoardRevision == "" {
+		return fmt.Errorf("hardware profile is missing a board revision")
+	}
+	if err := p.GPIO.CheckConflicts(Comms{}); err != nil {
+		return fmt.Errorf("invalid GPIO for board revision '%s': %w", p.BoardRevision, err)
+	}
+	hardwareMu.Lock()
+	defer hardwareMu.Unlock()
+	hardwareProfiles[p.BoardRevision] = p
+	return nil
+}
+
+// GetHardwareProfile returns the profile for a board revision.
+func GetHardwareProfile(boardRevision string) (HardwareProfile, bool) {
+	hardwareMu.RLock()
+	defer hardwareMu.RUnlock()
+	p, ok := hardwareProfiles[boardRevision]
+	return p, ok
+}
+
+// HardwareProfiles returns the sorted board revisions that have a profile.
+func HardwareProfiles() []string {
+	hardwareMu.RLock()
+	defer hardwareMu.RUnlock()
+	revisions := make([]string, 0, len(hardwareProfiles))
+	for r := range hardwareProfiles {
+		revisions = append(revisions, r)
+	}
+	sort.Strings(revisions)
+	return revisions
+}
+
+// hardwareProfileFor returns the profile for the hardware's board revision,
+// or the profile of the default board revision if it has none.
+func hardwareProfileFor(h Hardware) HardwareProfile {
+	hardwareMu.RLock()
+	defer hardwareMu.RUnlock()
+	if p, ok := hardwareProfiles[h.BoardRevision]; ok {
+		return p
+	}
+	return hardwareProfiles[DefaultBoardRevision]
+}
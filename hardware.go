@@ -0,0 +1,138 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const HardwareKey = "hardware"
+
+const (
+	CameraModelLepton3  = "lepton3"
+	CameraModelLepton35 = "lepton3.5"
+)
+
+func init() {
+	allSections[HardwareKey] = section{
+		key:         HardwareKey,
+		mapToStruct: hardwareMapToStruct,
+		validate:    hardwareValidateFunc,
+		defaultValue: func() interface{} {
+			return DefaultHardware()
+		},
+		pointerValue: func() interface{} {
+			return &Hardware{}
+		},
+	}
+}
+
+// Hardware is the board revision and camera the device has. The hardware
+// profile of the board revision supplies the defaults of other sections.
+type Hardware struct {
+	BoardRevision string `mapstructure:"board-revision"`
+	CameraModel   string `mapstructure:"camera-model"` // "lepton3" or "lepton3.5"
+}
+
+func DefaultHardware() Hardware {
+	return Hardware{
+		BoardRevision: DefaultBoardRevision,
+		CameraModel:   CameraModelLepton35,
+	}
+}
+
+// withDefaults returns the hardware with the default in place of an unknown
+// board revision or camera model.
+func (h Hardware) withDefaults() Hardware {
+	d := DefaultHardware()
+	if _, ok := GetHardwareProfile(h.BoardRevision); !ok {
+		h.BoardRevision = d.BoardRevision
+	}
+	switch h.CameraModel {
+	case CameraModelLepton3, CameraModelLepton35:
+	default:
+		h.CameraModel = d.CameraModel
+	}
+	return h
+}
+
+// Hardware returns the hardware the defaults of the config are given for.
+func (c *Config) Hardware() Hardware {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hardware
+}
+
+// readHardware reads the hardware the defaults are given for from the
+// hardware section. A camera model in the lepton section, such as one written
+// after detecting the camera, takes precedence over the one in the hardware
+// section.
+func (c *Config) readHardware() (Hardware, error) {
+	current, err := c.currentSectionWith(HardwareKey, nil)
+	if err != nil {
+		return Hardware{}, err
+	}
+	h, err := sectionStruct[Hardware](HardwareKey, current)
+	if err != nil {
+		return Hardware{}, err
+	}
+	if model := c.v.GetString(LeptonKey + ".camera-model"); model != "" {
+		h.CameraModel = model
+	}
+	return h.withDefaults(), nil
+}
+
+// updateHardware reads the hardware again if the key is in the hardware or
+// lepton section.
+func (c *Config) updateHardware(key string) error {
+	switch strings.Split(key, ".")[0] {
+	case HardwareKey, LeptonKey:
+		h, err := c.readHardware()
+		if err != nil {
+			return err
+		}
+		c.hardware = h
+	}
+	return nil
+}
+
+func hardwareMapToStruct(m map[string]interface{}) (interface{}, error) {
+	var s Hardware
+	if err := decodeStructFromMap(&s, m, nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func hardwareValidateFunc(hardware any) error {
+	h, err := ConvertToStruct[Hardware](hardware)
+	if err != nil {
+		return err
+	}
+	if h.BoardRevision != "" {
+		if _, ok := GetHardwareProfile(h.BoardRevision); !ok {
+			return fmt.Errorf("unknown board revision '%s'", h.BoardRevision)
+		}
+	}
+	switch h.CameraModel {
+	case "", CameraModelLepton3, CameraModelLepton35:
+	default:
+		return fmt.Errorf("camera model must be '%s' or '%s', got '%s'", CameraModelLepton3, CameraModelLepton35, h.CameraModel)
+	}
+	return nil
+}
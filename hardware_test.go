@@ -0,0 +1,69 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHardwareProfileDefaults(t *testing.T) {
+	defer newFs(t, "")()
+	defer func() {
+		hardwareMu.Lock()
+		delete(hardwareProfiles, "test-rev")
+		hardwareMu.Unlock()
+	}()
+
+	profile := HardwareProfile{
+		BoardRevision: "test-rev",
+		GPIO:          GPIO{ThermalCameraPower: "GPIO5", ModemPower: "GPIO6", UartTx: "GPIO14"},
+		Lepton:        Lepton{SPISpeed: 10000000, FrameOutput: "/var/run/lepton-frames"},
+	}
+	require.NoError(t, RegisterHardwareProfile(profile))
+	require.Contains(t, HardwareProfiles(), "test-rev")
+
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	other, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	require.Equal(t, DefaultHardware(), conf.Hardware())
+	require.Equal(t, "GPIO23", conf.GetDefaults()[GPIOKey].(GPIO).ThermalCameraPower)
+
+	// The hardware is used as soon as it is set, and only by that config.
+	require.NoError(t, conf.Set(HardwareKey, Hardware{BoardRevision: "test-rev", CameraModel: CameraModelLepton3}))
+	require.Equal(t, Hardware{BoardRevision: "test-rev", CameraModel: CameraModelLepton3}, conf.Hardware())
+	require.Equal(t, DefaultHardware(), other.Hardware())
+	require.Equal(t, "GPIO23", DefaultGPIO().ThermalCameraPower)
+	require.Equal(t, DefaultLepton35Motion(), GetDefaults()[ThermalMotionKey])
+
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	require.Equal(t, Hardware{BoardRevision: "test-rev", CameraModel: CameraModelLepton3}, conf.Hardware())
+	require.Equal(t, profile.GPIO, DefaultGPIOFor(conf.Hardware()))
+	require.Equal(t, profile.Lepton, DefaultLeptonFor(conf.Hardware()))
+	defaults := conf.GetDefaults()
+	require.Equal(t, profile.GPIO, defaults[GPIOKey])
+	require.Equal(t, profile.Lepton, defaults[LeptonKey])
+	require.Equal(t, DefaultLeptonMotion(), defaults[ThermalMotionKey])
+
+	// Sections still read over the profile defaults.
+	require.NoError(t, conf.SetFromMap(GPIOKey, map[string]any{"modem-power": "GPIO24"}, false))
+	gpio := DefaultGPIOFor(conf.Hardware())
+	require.NoError(t, conf.Unmarshal(GPIOKey, &gpio))
+	require.Equal(t, "GPIO5", gpio.ThermalCameraPower)
+	require.Equal(t, "GPIO24", gpio.ModemPower)
+}
+
+func TestHardwareValidation(t *testing.T) {
+	require.NoError(t, hardwareValidateFunc(DefaultHardware()))
+	require.NoError(t, hardwareValidateFunc(map[string]any{"camera-model": CameraModelLepton3}))
+	require.Error(t, hardwareValidateFunc(map[string]any{"board-revision": "unknown"}))
+	require.Error(t, hardwareValidateFunc(map[string]any{"camera-model": "boson"}))
+	require.Equal(t, DefaultHardware(), Hardware{BoardRevision: "unknown", CameraModel: "boson"}.withDefaults())
+
+	require.Error(t, RegisterHardwareProfile(HardwareProfile{}))
+	require.Error(t, RegisterHardwareProfile(HardwareProfile{
+		BoardRevision: "bad-rev",
+		GPIO:          GPIO{ThermalCameraPower: "GPIO5", ModemPower: "GPIO5"},
+	}))
+}
@@ -0,0 +1,133 @@
+package batterycalibrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	config "github.com/TheCacophonyProject/go-config"
+	"github.com/TheCacophonyProject/go-utils/logging"
+	"github.com/alexflint/go-arg"
+	"github.com/pelletier/go-toml"
+)
+
+var version = "<not set>"
+var log *logging.Logger
+
+type Args struct {
+	LogFile   string `arg:"positional,required" help:"CSV discharge log with rows of timestamp, voltage and optional current"`
+	Cells     int    `arg:"-n,--cells,required" help:"number of cells in series in the logged pack"`
+	Points    int    `arg:"-p,--points" help:"number of points in the fitted curve"`
+	Save      bool   `arg:"-s,--save" help:"save the fitted curve to the battery section as a custom profile"`
+	ConfigDir string `arg:"-c,--config" help:"path to configuration directory"`
+	logging.LogArgs
+}
+
+var defaultArgs = Args{
+	Points:    config.MinCalibrationPoints,
+	ConfigDir: config.DefaultConfigDir,
+}
+
+func procArgs(input []string) (Args, error) {
+	args := defaultArgs
+
+	parser, err := arg.NewParser(arg.Config{}, &args)
+	if err != nil {
+		return Args{}, err
+	}
+	err = parser.Parse(input)
+	if errors.Is(err, arg.ErrHelp) {
+		parser.WriteHelp(os.Stdout)
+		os.Exit(0)
+	}
+	if errors.Is(err, arg.ErrVersion) {
+		fmt.Println(version)
+		os.Exit(0)
+	}
+	return args, err
+}
+
+func Run(inputArgs []string, ver string) error {
+	version = ver
+	args, err := procArgs(inputArgs)
+	if err != nil {
+		return fmt.Errorf("failed to parse args: %v", err)
+	}
+	log = logging.NewLogger(args.LogLevel)
+
+	log.Printf("Running version: %s", version)
+
+	curve, err := calibrate(args.LogFile, args.Cells, args.Points)
+	if err != nil {
+		return err
+	}
+	if err := printCurve(curve); err != nil {
+		return err
+	}
+
+	if args.Save {
+		return saveCurve(args.ConfigDir, curve)
+	}
+	return nil
+}
+
+func calibrate(logFile string, cells, points int) (config.BatteryType, error) {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return config.BatteryType{}, err
+	}
+	defer f.Close()
+
+	samples, err := config.ParseDischargeLog(f)
+	if err != nil {
+		return config.BatteryType{}, fmt.Errorf("failed to parse '%s': %v", logFile, err)
+	}
+	log.Printf("read %d samples from '%s'", len(samples), logFile)
+	return config.CalibrateDischargeCurve(samples, cells, points)
+}
+
+func printCurve(curve config.BatteryType) error {
+	b := config.Battery{}
+	if err := b.SetCustomProfile(curve); err != nil {
+		return err
+	}
+	t, err := toml.TreeFromMap(map[string]interface{}{
+		config.BatteryKey: customProfileMap(b),
+	})
+	if err != nil {
+		return err
+	}
+	log.Println(t)
+	return nil
+}
+
+func saveCurve(configDir string, curve config.BatteryType) error {
+	conf, err := config.New(configDir)
+	if err != nil {
+		return err
+	}
+	var b config.Battery
+	if err := conf.Unmarshal(config.BatteryKey, &b); err != nil {
+		return err
+	}
+	if err := b.SetCustomProfile(curve); err != nil {
+		return err
+	}
+	if err := conf.SetFromMap(config.BatteryKey, customProfileMap(b), false); err != nil {
+		return err
+	}
+	log.Printf("saved custom battery profile to '%s'", configDir)
+	return nil
+}
+
+// customProfileMap returns the battery section fields set by SetCustomProfile
+func customProfileMap(b config.Battery) map[string]interface{} {
+	return map[string]interface{}{
+		"chemistry":           b.Chemistry,
+		"manually-configured": b.ManuallyConfigured,
+		"custom-min-voltage":  b.CustomMinVoltage,
+		"custom-max-voltage":  b.CustomMaxVoltage,
+		"custom-voltages":     b.CustomVoltages,
+		"custom-percent":      b.CustomPercent,
+	}
+}
@@ -23,7 +23,6 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
-	"strings"
 	"time"
 
 	api "github.com/TheCacophonyProject/go-api"
@@ -32,6 +31,7 @@ import (
 	"github.com/TheCacophonyProject/modemd/connrequester"
 	"github.com/TheCacophonyProject/modemd/modemlistener"
 	"github.com/alexflint/go-arg"
+	"github.com/pelletier/go-toml"
 	"github.com/rjeczalik/notify"
 )
 
@@ -226,44 +226,64 @@ func NewSyncService() (*SyncService, error) {
 	}, nil
 }
 
+// PrintConfig logs config.toml with any secret values redacted.
 func (s *SyncService) PrintConfig() {
 	content, err := os.ReadFile(filepath.Join(configDir, config.ConfigFileName))
 	if err != nil {
 		log.Errorf("Error reading config file: %v", err)
 		return
 	}
+	redacted, err := redactTOML(content)
+	if err != nil {
+		log.Errorf("Error parsing config file: %v", err)
+		return
+	}
+	log.Info("Current Config:\n" + redacted)
+}
 
-	inSecretsSection := false
-	var out strings.Builder
-	for line := range strings.SplitSeq(string(content), "\n") {
-		// Just some white space.
-		if strings.TrimSpace(line) == "" {
-			out.WriteString(line + "\n")
-			continue
-		}
-		// Detect when the secrets section starts [secrets].
-		if strings.HasPrefix(line, "[secrets]") {
-			inSecretsSection = true
-			out.WriteString(line + "\n")
+func redactTOML(content []byte) (string, error) {
+	tree, err := toml.LoadBytes(content)
+	if err != nil {
+		return "", err
+	}
+	tree, err = toml.TreeFromMap(config.Redact(tree.ToMap()))
+	if err != nil {
+		return "", err
+	}
+	return tree.String(), nil
+}
+
+// redact returns a copy of the settings, keyed by API section name, with
+// secret values redacted.
+func (sections Sections) redact(settings map[string]any) map[string]any {
+	out := make(map[string]any, len(settings))
+	for name, value := range settings {
+		out[name] = value
+	}
+	for _, section := range sections {
+		sectionMap, ok := settings[section.Name].(map[string]any)
+		if !ok {
 			continue
 		}
-		// Detect when secrets section ends.
-		if strings.HasPrefix(line, "[") {
-			inSecretsSection = false
+		redacted := make(map[string]any, len(sectionMap))
+		for k, v := range sectionMap {
+			redacted[k] = v
 		}
-		if inSecretsSection {
-			parts := strings.Split(line, "=")
-			if len(parts) >= 2 {
-				out.WriteString(fmt.Sprintf("%s = ************\n", parts[0]))
-			} else {
-				log.Warn("Not too sure how to parse line in secrets section")
+		for _, mapping := range section.Mappings {
+			if v, ok := redacted[mapping.APIKey]; ok {
+				redacted[mapping.APIKey] = redactValue(section.Key, mapping.MapKey, v)
 			}
-		} else {
-			out.WriteString(line + "\n")
 		}
+		out[section.Name] = redacted
 	}
+	return out
+}
 
-	log.Info("Current Config:\n" + out.String())
+func redactValue(sectionKey, mapKey string, value any) any {
+	if config.IsSecret(sectionKey + "." + mapKey) {
+		return config.RedactedValue
+	}
+	return value
 }
 
 func (s *SyncService) syncSettings() error {
@@ -282,7 +302,7 @@ func (s *SyncService) syncSettings() error {
 	if err != nil {
 		return fmt.Errorf("failed to read current settings: %v", err)
 	}
-	log.Printf("Device Settings: %+v", deviceSettings)
+	log.Printf("Device Settings: %+v", ConfigSections.redact(deviceSettings))
 
 	// Send config to server and get the updated settings
 	serverSettings, err := s.uploadSettingsToAPI(deviceSettings)
@@ -362,7 +382,7 @@ func (s *SyncService) filterUnchangedSettings(mappedSettings, deviceSettings map
 		}
 	}
 
-	log.Printf("Filtered settings: %+v", filteredSettings)
+	log.Printf("Filtered settings: %+v", config.Redact(filteredSettings))
 	return filteredSettings
 }
 
@@ -443,16 +463,16 @@ func (s *SyncService) mapServerSettingsToConfig(serverSettings map[string]any) m
 		}
 	}
 
-	log.Printf("Final mapped settings: %+v", mappedSettings)
+	log.Printf("Final mapped settings: %+v", config.Redact(mappedSettings))
 	return mappedSettings
 }
 
 func (s *SyncService) updateConfig(settings map[string]any) error {
-	fmt.Printf("Settings: %v\n", settings)
+	fmt.Printf("Settings: %v\n", config.Redact(settings))
 	for _, section := range ConfigSections {
 		if sectionSettings, ok := settings[section.Key]; ok {
 			newConfig := sectionSettings.(map[string]any)
-			fmt.Printf("New config: %v\n", newConfig)
+			fmt.Printf("New config: %v\n", config.Redact(map[string]any{section.Key: newConfig})[section.Key])
 			err := s.config.SetFromMap(section.Key, newConfig, true)
 			if err != nil {
 				return fmt.Errorf("failed to set section %s: %v", section.Name, err)
@@ -476,7 +496,7 @@ func (s *SyncService) uploadSettingsToAPI(settings map[string]any) (map[string]a
 				if value, ok := settings[section.Name].(map[string]any)[mapping.APIKey]; ok {
 					// Check if the value is non-empty before adding it to the map
 					if !isEmptyValue(value) {
-						log.Printf("Adding value to settings: %v", value)
+						log.Printf("Adding value to settings: %v", redactValue(section.Key, mapping.MapKey, value))
 						sectionMap[mapping.APIKey] = value
 					}
 				}
@@ -491,7 +511,7 @@ func (s *SyncService) uploadSettingsToAPI(settings map[string]any) (map[string]a
 	if err != nil {
 		return nil, fmt.Errorf("failed to update settings on API: %v", err)
 	}
-	log.Printf("Update Settings: %+v", updatedSettings)
+	log.Printf("Update Settings: %+v", ConfigSections.redact(updatedSettings))
 	return updatedSettings, nil
 }
 
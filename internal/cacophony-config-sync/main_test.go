@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	config "github.com/TheCacophonyProject/go-config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -232,3 +233,38 @@ func TestFilterUnchangedSettings(t *testing.T) {
 		})
 	}
 }
+
+func TestRedactSettings(t *testing.T) {
+	sections := Sections{
+		{
+			Name: "secrets",
+			Key:  config.SecretsKey,
+			Mappings: []Mapping{
+				{APIKey: "devicePassword", ConfigKey: "DevicePassword", MapKey: "device-password"},
+			},
+		},
+		{
+			Name: "windows",
+			Key:  config.WindowsKey,
+			Mappings: []Mapping{
+				{APIKey: "startRecording", ConfigKey: "StartRecording", MapKey: "start-recording"},
+			},
+		},
+	}
+	settings := map[string]any{
+		"secrets": map[string]any{"devicePassword": "hunter2"},
+		"windows": map[string]any{"startRecording": "-30m"},
+	}
+	redacted := sections.redact(settings)
+	assert.Equal(t, map[string]any{
+		"secrets": map[string]any{"devicePassword": config.RedactedValue},
+		"windows": map[string]any{"startRecording": "-30m"},
+	}, redacted)
+	assert.Equal(t, "hunter2", settings["secrets"].(map[string]any)["devicePassword"])
+
+	printed, err := redactTOML([]byte("[secrets]\ndevice-password = \"hunter2\"\n[windows]\nstart-recording = \"-30m\"\n"))
+	assert.NoError(t, err)
+	assert.NotContains(t, printed, "hunter2")
+	assert.Contains(t, printed, config.RedactedValue)
+	assert.Contains(t, printed, "-30m")
+}
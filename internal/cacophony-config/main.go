@@ -104,12 +104,20 @@ type setting struct {
 	value   string
 }
 
+func (s setting) String() string {
+	value := s.value
+	if config.IsSecret(s.section + "." + s.field) {
+		value = config.RedactedValue
+	}
+	return fmt.Sprintf("%s.%s=%s", s.section, s.field, value)
+}
+
 func writeNewSettings(args *Args) error {
 	settings, err := getNewSettings(args.Input)
 	if err != nil {
 		return err
 	}
-	log.Printf("new settings: %+v", settings)
+	log.Printf("new settings: %v", settings)
 
 	conf, err := config.New(args.ConfigDir)
 	if err != nil {
@@ -138,15 +146,37 @@ func writeNewSettings(args *Args) error {
 }
 
 func printSection(section string, conf *config.Config) error {
+	t, err := sectionTree(section, conf)
+	if err != nil {
+		return err
+	}
+	log.Println(t)
+	if section == config.ThermalMotionKey {
+		return printThresholds(conf)
+	}
+	return nil
+}
+
+// sectionTree returns the section for printing, with secrets redacted.
+func sectionTree(section string, conf *config.Config) (*toml.Tree, error) {
 	var m map[string]interface{}
 	if err := conf.Unmarshal(section, &m); err != nil {
-		return err
+		return nil, err
 	}
-	t, err := toml.TreeFromMap(map[string]interface{}{section: m})
-	if err != nil {
+	return toml.TreeFromMap(config.Redact(map[string]interface{}{section: m}))
+}
+
+// printThresholds shows the thermal-motion thresholds in human units.
+func printThresholds(conf *config.Config) error {
+	profile := conf.ThresholdProfile()
+	tm := profile.Defaults()
+	if err := conf.Unmarshal(config.ThermalMotionKey, &tm); err != nil {
 		return err
 	}
-	log.Println(t)
+	log.Printf("# threshold profile: %s", profile.Name)
+	for _, line := range profile.FormatThresholds(tm) {
+		log.Printf("# %s", line)
+	}
 	return nil
 }
 
@@ -1,8 +1,13 @@
 package cacophonyconfig
 
 import (
+	"fmt"
+	"path"
 	"testing"
 
+	config "github.com/TheCacophonyProject/go-config"
+	"github.com/TheCacophonyProject/go-config/configtest"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 )
 
@@ -30,3 +35,29 @@ func TestBadArgs(t *testing.T) {
 	_, err = getNewSettings([]string{"cat.dog.foo=bar"})
 	require.Error(t, err)
 }
+
+func TestSecretsRedacted(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	config.SetFs(fs)
+	defer config.SetFs(afero.NewOsFs())
+	lockFileFunc, cleanup := configtest.WriteConfigFromBytes(t, []byte{}, path.Join(config.DefaultConfigDir, config.ConfigFileName), fs)
+	defer cleanup()
+	config.SetLockFilePath(lockFileFunc)
+
+	conf, err := config.New(config.DefaultConfigDir)
+	require.NoError(t, err)
+	require.NoError(t, conf.Set(config.SecretsKey, config.Secrets{
+		ModemProfiles: map[string]config.ModemSecrets{"spark": {Password: "sim-password", PIN: "1234"}},
+	}))
+
+	tree, err := sectionTree(config.SecretsKey, conf)
+	require.NoError(t, err)
+	require.NotContains(t, tree.String(), "sim-password")
+	require.NotContains(t, tree.String(), "1234")
+	require.Contains(t, tree.String(), config.RedactedValue)
+
+	s := setting{section: config.SecretsKey, field: "device-password", value: "hunter2"}
+	require.NotContains(t, fmt.Sprintf("%v", []setting{s}), "hunter2")
+	s = setting{section: config.WindowsKey, field: "start-recording", value: "-1h"}
+	require.Equal(t, "windows.start-recording=-1h", s.String())
+}
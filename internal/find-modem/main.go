@@ -0,0 +1,80 @@
+package findmodem
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	config "github.com/TheCacophonyProject/go-config"
+	"github.com/TheCacophonyProject/go-utils/logging"
+	"github.com/alexflint/go-arg"
+)
+
+var version = "<not set>"
+var log *logging.Logger
+
+type Args struct {
+	ConfigDir      string `arg:"-c,--config" help:"path to configuration directory"`
+	USBDevicesPath string `arg:"--usb-devices" help:"path to the USB devices in sysfs"`
+	logging.LogArgs
+}
+
+var defaultArgs = Args{
+	ConfigDir:      config.DefaultConfigDir,
+	USBDevicesPath: config.DefaultUSBDevicesPath,
+}
+
+func procArgs(input []string) (Args, error) {
+	args := defaultArgs
+
+	parser, err := arg.NewParser(arg.Config{}, &args)
+	if err != nil {
+		return Args{}, err
+	}
+	err = parser.Parse(input)
+	if errors.Is(err, arg.ErrHelp) {
+		parser.WriteHelp(os.Stdout)
+		os.Exit(0)
+	}
+	if errors.Is(err, arg.ErrVersion) {
+		fmt.Println(version)
+		os.Exit(0)
+	}
+	return args, err
+}
+
+func Run(inputArgs []string, ver string) error {
+	version = ver
+	args, err := procArgs(inputArgs)
+	if err != nil {
+		return fmt.Errorf("failed to parse args: %v", err)
+	}
+	log = logging.NewLogger(args.LogLevel)
+
+	log.Printf("Running version: %s", version)
+
+	conf, err := config.New(args.ConfigDir)
+	if err != nil {
+		return err
+	}
+	modemd := config.DefaultModemd()
+	if err := conf.Unmarshal(config.ModemdKey, &modemd); err != nil {
+		return err
+	}
+
+	found, err := modemd.FindModem(args.USBDevicesPath)
+	if errors.Is(err, config.ErrModemNotFound) {
+		log.Println("no configured modem is attached")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	iface := found.Interface
+	if iface == "" {
+		iface = "none yet"
+	}
+	log.Printf("found '%s' (%s) on USB device %s", found.Name, found.VendorProductID, found.USBDevice)
+	log.Printf("network interface: %s, configured: %s", iface, found.NetDev)
+	return nil
+}
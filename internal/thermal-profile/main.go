@@ -0,0 +1,70 @@
+package thermalprofile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	config "github.com/TheCacophonyProject/go-config"
+	"github.com/TheCacophonyProject/go-utils/logging"
+	"github.com/alexflint/go-arg"
+)
+
+var version = "<not set>"
+var log *logging.Logger
+
+type Args struct {
+	ConfigDir string `arg:"-c,--config" help:"path to configuration directory"`
+	logging.LogArgs
+}
+
+var defaultArgs = Args{
+	ConfigDir: config.DefaultConfigDir,
+}
+
+func procArgs(input []string) (Args, error) {
+	args := defaultArgs
+
+	parser, err := arg.NewParser(arg.Config{}, &args)
+	if err != nil {
+		return Args{}, err
+	}
+	err = parser.Parse(input)
+	if errors.Is(err, arg.ErrHelp) {
+		parser.WriteHelp(os.Stdout)
+		os.Exit(0)
+	}
+	if errors.Is(err, arg.ErrVersion) {
+		fmt.Println(version)
+		os.Exit(0)
+	}
+	return args, err
+}
+
+func Run(inputArgs []string, ver string) error {
+	version = ver
+	args, err := procArgs(inputArgs)
+	if err != nil {
+		return fmt.Errorf("failed to parse args: %v", err)
+	}
+	log = logging.NewLogger(args.LogLevel)
+
+	log.Printf("Running version: %s", version)
+
+	conf, err := config.New(args.ConfigDir)
+	if err != nil {
+		return err
+	}
+	profile := conf.ThresholdProfile()
+	motion := profile.Defaults()
+	if err := conf.Unmarshal(config.ThermalMotionKey, &motion); err != nil {
+		return err
+	}
+
+	log.Printf("camera model: %s", profile.CameraModel)
+	log.Printf("threshold profile: %s", profile.Name)
+	for _, line := range profile.FormatThresholds(motion) {
+		log.Println(line)
+	}
+	return nil
+}
@@ -16,17 +16,22 @@
 
 package config
 
+import "fmt"
+
 const LeptonKey = "lepton"
 
 func init() {
 	allSections[LeptonKey] = section{
 		key:         LeptonKey,
 		mapToStruct: leptonMapToStruct,
-		validate:    noValidateFunc,
+		validate:    leptonValidateFunc,
 
 		defaultValue: func() interface{} {
 			return DefaultLepton()
 		},
+		hardwareDefault: func(h Hardware) interface{} {
+			return DefaultLeptonFor(h)
+		},
 		pointerValue: func() interface{} {
 			return &Lepton{}
 		},
@@ -36,13 +41,17 @@ func init() {
 type Lepton struct {
 	SPISpeed    int64  `mapstructure:"spi-speed"`
 	FrameOutput string `mapstructure:"frame-output"`
+	CameraModel string `mapstructure:"camera-model"` // Model of the attached Lepton, takes precedence over the hardware section
 }
 
+// DefaultLepton returns the Lepton settings of the default hardware.
 func DefaultLepton() Lepton {
-	return Lepton{
-		SPISpeed:    20000000,
-		FrameOutput: "/var/run/lepton-frames",
-	}
+	return DefaultLeptonFor(DefaultHardware())
+}
+
+// DefaultLeptonFor returns the Lepton settings of the hardware profile for the hardware.
+func DefaultLeptonFor(h Hardware) Lepton {
+	return hardwareProfileFor(h).Lepton
 }
 
 func leptonMapToStruct(m map[string]interface{}) (interface{}, error) {
@@ -52,3 +61,16 @@ func leptonMapToStruct(m map[string]interface{}) (interface{}, error) {
 	}
 	return s, nil
 }
+
+func leptonValidateFunc(lepton any) error {
+	l, err := ConvertToStruct[Lepton](lepton)
+	if err != nil {
+		return err
+	}
+	switch l.CameraModel {
+	case "", CameraModelLepton3, CameraModelLepton35:
+	default:
+		return fmt.Errorf("camera model must be '%s' or '%s', got '%s'", CameraModelLepton3, CameraModelLepton35, l.CameraModel)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	NetworkModeAuto = "auto"
+	NetworkMode2G   = "2g"
+	NetworkMode3G   = "3g"
+	NetworkMode4G   = "4g"
+	NetworkModeLTEM = "lte-m"
+)
+
+// ModemProfile holds the carrier settings for a modem and/or SIM. A profile
+// applies to modems with a matching VendorProductID and SIMs with an ICCID
+// starting with ICCIDPrefix, either can be left empty to match anything.
+//
+// The password and SIM PIN for a profile are kept in the secrets section under
+// the profile name, see Secrets.ModemSecrets.
+type ModemProfile struct {
+	Name            string `mapstructure:"name"`
+	VendorProductID string `mapstructure:"vendor-product-id"`
+	ICCIDPrefix     string `mapstructure:"iccid-prefix"`
+	APN             string `mapstructure:"apn"`
+	Username        string `mapstructure:"username"`
+	NetworkMode     string `mapstructure:"network-mode"` // "auto", "2g", "3g", "4g" or "lte-m"
+	Bands           []int  `mapstructure:"bands"`        // Preferred bands, empty for any
+}
+
+var iccidPrefixRegex = regexp.MustCompile(`^[0-9]{1,20}$`)
+
+// ProfileFor returns the profile for a modem and SIM. When more than one
+// profile matches, the one with the longest ICCID prefix is used, then one
+// that names the modem, then the first listed.
+func (m Modemd) ProfileFor(vendorProductID, iccid string) (ModemProfile, bool) {
+	best, bestScore := -1, -1
+	for i, p := range m.Profiles {
+		if p.VendorProductID != "" && !strings.EqualFold(p.VendorProductID, vendorProductID) {
+			continue
+		}
+		if !strings.HasPrefix(iccid, p.ICCIDPrefix) {
+			continue
+		}
+		score := 2 * len(p.ICCIDPrefix)
+		if p.VendorProductID != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return ModemProfile{}, false
+	}
+	return m.Profiles[best], true
+}
+
+func validateModemProfiles(profiles []ModemProfile) error {
+	names := map[string]bool{}
+	for _, p := range profiles {
+		if p.Name == "" {
+			return fmt.Errorf("modem profile is missing a name")
+		}
+		if names[p.Name] {
+			return fmt.Errorf("modem profile '%s' is given more than once", p.Name)
+		}
+		names[p.Name] = true
+
+		if p.VendorProductID != "" && !vendorProductIDRegex.MatchString(p.VendorProductID) {
+			return fmt.Errorf("modem profile '%s' vendor product ID must be 'xxxx:xxxx' in hex, got '%s'", p.Name, p.VendorProductID)
+		}
+		if p.ICCIDPrefix != "" && !iccidPrefixRegex.MatchString(p.ICCIDPrefix) {
+			return fmt.Errorf("modem profile '%s' ICCID prefix must be up to 20 digits, got '%s'", p.Name, p.ICCIDPrefix)
+		}
+		switch p.NetworkMode {
+		case "", NetworkModeAuto, NetworkMode2G, NetworkMode3G, NetworkMode4G, NetworkModeLTEM:
+		default:
+			return fmt.Errorf("modem profile '%s' network mode must be one of '%s', '%s', '%s', '%s' or '%s', got '%s'",
+				p.Name, NetworkModeAuto, NetworkMode2G, NetworkMode3G, NetworkMode4G, NetworkModeLTEM, p.NetworkMode)
+		}
+		for _, band := range p.Bands {
+			if band < 1 || band > 255 {
+				return fmt.Errorf("modem profile '%s' band must be between 1 and 255, got %d", p.Name, band)
+			}
+		}
+	}
+	return nil
+}
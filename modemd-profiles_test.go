@@ -0,0 +1,93 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testModemProfiles() Modemd {
+	m := DefaultModemd()
+	m.Profiles = []ModemProfile{
+		{Name: "default", APN: "internet"},
+		{Name: "huawei", VendorProductID: "12d1:14db", APN: "huawei.internet"},
+		{Name: "spark", ICCIDPrefix: "896405", APN: "direct", NetworkMode: NetworkModeLTEM},
+		{Name: "spark-huawei", VendorProductID: "12d1:14db", ICCIDPrefix: "896405", APN: "direct", Bands: []int{3, 28}},
+		{Name: "spark-iot", ICCIDPrefix: "89640512", APN: "iot", Username: "user"},
+	}
+	return m
+}
+
+func TestModemProfileFor(t *testing.T) {
+	m := testModemProfiles()
+	for _, tc := range []struct {
+		vendorProductID string
+		iccid           string
+		expected        string
+	}{
+		{"19d2:1405", "8961000000000000000", "default"},
+		{"12d1:14DB", "8961000000000000000", "huawei"},
+		{"19d2:1405", "8964050000000000000", "spark"},
+		{"12d1:14db", "8964050000000000000", "spark-huawei"},
+		{"12d1:14db", "8964051200000000000", "spark-iot"},
+		{"12d1:14db", "", "huawei"},
+	} {
+		p, ok := m.ProfileFor(tc.vendorProductID, tc.iccid)
+		require.True(t, ok)
+		require.Equal(t, tc.expected, p.Name, "%s %s", tc.vendorProductID, tc.iccid)
+	}
+
+	m.Profiles = m.Profiles[1:]
+	_, ok := m.ProfileFor("19d2:1405", "8961000000000000000")
+	require.False(t, ok)
+}
+
+func TestModemProfileValidation(t *testing.T) {
+	require.NoError(t, modemdValidateFunc(testModemProfiles()))
+
+	for name, modify := range map[string]func(p *ModemProfile){
+		"no name":      func(p *ModemProfile) { p.Name = "" },
+		"duplicate":    func(p *ModemProfile) { p.Name = "default" },
+		"iccid":        func(p *ModemProfile) { p.ICCIDPrefix = "89-64" },
+		"network mode": func(p *ModemProfile) { p.NetworkMode = "5g" },
+		"band":         func(p *ModemProfile) { p.Bands = []int{0} },
+	} {
+		m := testModemProfiles()
+		modify(&m.Profiles[2])
+		require.Error(t, modemdValidateFunc(m), name)
+	}
+
+	require.NoError(t, secretsValidateFunc(Secrets{ModemProfiles: map[string]ModemSecrets{"spark": {PIN: "1234"}}}))
+	require.Error(t, secretsValidateFunc(Secrets{ModemProfiles: map[string]ModemSecrets{"spark": {PIN: "12"}}}))
+}
+
+func TestWritingModemProfiles(t *testing.T) {
+	defer newFs(t, "")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	m := testModemProfiles()
+	require.NoError(t, conf.Set(ModemdKey, m))
+	require.NoError(t, conf.Set(SecretsKey, Secrets{
+		ModemProfiles: map[string]ModemSecrets{"spark-iot": {Password: "pass", PIN: "1234"}},
+	}))
+
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	var m2 Modemd
+	require.NoError(t, conf.Unmarshal(ModemdKey, &m2))
+	require.Len(t, m2.Profiles, len(m.Profiles))
+	for i := range m.Profiles {
+		require.Equal(t, m.Profiles[i].Name, m2.Profiles[i].Name)
+		require.Equal(t, m.Profiles[i].APN, m2.Profiles[i].APN)
+		require.ElementsMatch(t, m.Profiles[i].Bands, m2.Profiles[i].Bands)
+	}
+
+	p, ok := m2.ProfileFor("12d1:14db", "8964051200000000000")
+	require.True(t, ok)
+	var secrets Secrets
+	require.NoError(t, conf.Unmarshal(SecretsKey, &secrets))
+	ms, ok := secrets.ModemSecrets(p.Name)
+	require.True(t, ok)
+	require.Equal(t, ModemSecrets{Password: "pass", PIN: "1234"}, ms)
+}
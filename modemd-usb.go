@@ -0,0 +1,106 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// DefaultUSBDevicesPath is where sysfs lists the attached USB devices
+const DefaultUSBDevicesPath = "/sys/bus/usb/devices"
+
+var ErrModemNotFound = errors.New("no configured modem found")
+
+// DetectedModem is a configured modem that is attached.
+type DetectedModem struct {
+	Modem
+	USBDevice string // Name of the device in sysfs, e.g. "1-1.3"
+	Interface string // Network interface the modem has, empty if it doesn't have one yet
+}
+
+// FindModem scans the USB devices listed in sysfs at usbDevicesPath for one of
+// the configured modems, matching by VendorProductID. If more than one is
+// attached the one listed first in Modems is returned.
+func (m Modemd) FindModem(usbDevicesPath string) (DetectedModem, error) {
+	entries, err := afero.ReadDir(fs, usbDevicesPath)
+	if err != nil {
+		return DetectedModem{}, err
+	}
+
+	attached := map[string]string{} // Vendor product ID to device
+	for _, e := range entries {
+		// Interfaces of a device are listed as "<device>:<config>.<interface>"
+		if strings.Contains(e.Name(), ":") {
+			continue
+		}
+		id, ok := readVendorProductID(path.Join(usbDevicesPath, e.Name()))
+		if !ok {
+			continue
+		}
+		if _, ok := attached[id]; !ok {
+			attached[id] = e.Name()
+		}
+	}
+
+	for _, modem := range m.Modems {
+		device, ok := attached[strings.ToLower(modem.VendorProductID)]
+		if !ok {
+			continue
+		}
+		return DetectedModem{
+			Modem:     modem,
+			USBDevice: device,
+			Interface: findUSBNetInterface(usbDevicesPath, device, entries),
+		}, nil
+	}
+	return DetectedModem{}, ErrModemNotFound
+}
+
+func readVendorProductID(devicePath string) (string, bool) {
+	vendor, err := afero.ReadFile(fs, path.Join(devicePath, "idVendor"))
+	if err != nil {
+		return "", false
+	}
+	product, err := afero.ReadFile(fs, path.Join(devicePath, "idProduct"))
+	if err != nil {
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSpace(string(vendor)) + ":" + strings.TrimSpace(string(product))), true
+}
+
+// findUSBNetInterface returns the network interface of the device's lowest numbered
+// USB interface that has one. entries must be sorted by name, as afero.ReadDir does.
+func findUSBNetInterface(usbDevicesPath, device string, entries []os.FileInfo) string {
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), device+":") {
+			continue
+		}
+		netEntries, err := afero.ReadDir(fs, path.Join(usbDevicesPath, e.Name(), "net"))
+		if err != nil {
+			continue
+		}
+		if len(netEntries) > 0 {
+			return netEntries[0].Name()
+		}
+	}
+	return ""
+}
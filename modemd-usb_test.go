@@ -0,0 +1,49 @@
+package config
+
+import (
+	"path"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+const testUSBDevicesPath = "/sys/bus/usb/devices"
+
+func addUSBDevice(t *testing.T, name, vendor, product string, netDevs map[string]string) {
+	dir := path.Join(testUSBDevicesPath, name)
+	require.NoError(t, afero.WriteFile(fs, path.Join(dir, "idVendor"), []byte(vendor+"\n"), 0644))
+	require.NoError(t, afero.WriteFile(fs, path.Join(dir, "idProduct"), []byte(product+"\n"), 0644))
+	for iface, netDev := range netDevs {
+		require.NoError(t, fs.MkdirAll(path.Join(testUSBDevicesPath, name+":"+iface, "net", netDev), 0755))
+	}
+}
+
+func TestFindModem(t *testing.T) {
+	defer newFs(t, "")()
+	m := DefaultModemd()
+
+	require.NoError(t, fs.MkdirAll(testUSBDevicesPath, 0755))
+	_, err := m.FindModem(testUSBDevicesPath)
+	require.ErrorIs(t, err, ErrModemNotFound)
+
+	addUSBDevice(t, "usb1", "1d6b", "0002", nil)
+	addUSBDevice(t, "1-1", "0424", "9514", nil)
+	addUSBDevice(t, "1-1.3", "1E0E", "9011", map[string]string{"1.0": "", "1.4": "usb0"})
+
+	found, err := m.FindModem(testUSBDevicesPath)
+	require.NoError(t, err)
+	require.Equal(t, "Qualcomm", found.Name)
+	require.Equal(t, "1-1.3", found.USBDevice)
+	require.Equal(t, "usb0", found.Interface)
+
+	// The first configured modem is preferred, even without a network interface yet.
+	addUSBDevice(t, "1-1.2", "12d1", "14db", nil)
+	found, err = m.FindModem(testUSBDevicesPath)
+	require.NoError(t, err)
+	require.Equal(t, "Huawei 4G modem", found.Name)
+	require.Equal(t, "", found.Interface)
+
+	_, err = m.FindModem("/not/a/path")
+	require.Error(t, err)
+}
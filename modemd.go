@@ -17,7 +17,10 @@
 package config
 
 import (
+	"fmt"
 	"reflect"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
@@ -29,7 +32,7 @@ func init() {
 	allSections[ModemdKey] = section{
 		key:         ModemdKey,
 		mapToStruct: modemdMapToStruct,
-		validate:    noValidateFunc,
+		validate:    modemdValidateFunc,
 		defaultValue: func() interface{} {
 			return DefaultModemd()
 		},
@@ -41,16 +44,17 @@ func init() {
 }
 
 type Modemd struct {
-	TestInterval           time.Duration `mapstructure:"test-interval"`
-	InitialOnDuration      time.Duration `mapstructure:"initial-on-duration"`
-	FindModemTimeout       time.Duration `mapstructure:"find-modem-timeout"`
-	ConnectionTimeout      time.Duration `mapstructure:"connection-timeout"`
-	RequestOnDuration      time.Duration `mapstructure:"request-on-duration"`
-	RetryInterval          time.Duration `mapstructure:"retry-interval"`
-	RetryFindModemInterval time.Duration `mapstructure:"retry-find-modem-interval"`
-	MinConnDuration        time.Duration `mapstructure:"min-connection-duration"`
-	MaxOffDuration         time.Duration `mapstructure:"max-off-duration"`
-	Modems                 []Modem       `mapstructure:"modems"`
+	TestInterval           time.Duration  `mapstructure:"test-interval"`
+	InitialOnDuration      time.Duration  `mapstructure:"initial-on-duration"`
+	FindModemTimeout       time.Duration  `mapstructure:"find-modem-timeout"`
+	ConnectionTimeout      time.Duration  `mapstructure:"connection-timeout"`
+	RequestOnDuration      time.Duration  `mapstructure:"request-on-duration"`
+	RetryInterval          time.Duration  `mapstructure:"retry-interval"`
+	RetryFindModemInterval time.Duration  `mapstructure:"retry-find-modem-interval"`
+	MinConnDuration        time.Duration  `mapstructure:"min-connection-duration"`
+	MaxOffDuration         time.Duration  `mapstructure:"max-off-duration"`
+	Modems                 []Modem        `mapstructure:"modems"`
+	Profiles               []ModemProfile `mapstructure:"profiles"`
 }
 
 type Modem struct {
@@ -96,6 +100,11 @@ func modemdToMap(f reflect.Type, t reflect.Type, data interface{}) (interface{},
 			return nil, err
 		}
 		m["modems"] = modems
+		profiles := []map[string]interface{}{}
+		if err := mapstructure.Decode(data.(Modemd).Profiles, &profiles); err != nil {
+			return nil, err
+		}
+		m["profiles"] = profiles
 		return m, nil
 	default:
 		return data, nil
@@ -109,3 +118,91 @@ func modemdMapToStruct(m map[string]interface{}) (interface{}, error) {
 	}
 	return s, nil
 }
+
+var vendorProductIDRegex = regexp.MustCompile(`^[0-9a-fA-F]{4}:[0-9a-fA-F]{4}$`)
+
+func modemdValidateFunc(modemd any) error {
+	md, err := sectionStruct[Modemd](ModemdKey, modemd)
+	if err != nil {
+		return err
+	}
+	if err := validateModemdTimings(md); err != nil {
+		return err
+	}
+	if err := validateModems(md.Modems); err != nil {
+		return err
+	}
+	return validateModemProfiles(md.Profiles)
+}
+
+func validateModemdTimings(md Modemd) error {
+	for _, d := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"test-interval", md.TestInterval},
+		{"initial-on-duration", md.InitialOnDuration},
+		{"find-modem-timeout", md.FindModemTimeout},
+		{"connection-timeout", md.ConnectionTimeout},
+		{"request-on-duration", md.RequestOnDuration},
+		{"retry-interval", md.RetryInterval},
+		{"retry-find-modem-interval", md.RetryFindModemInterval},
+		{"max-off-duration", md.MaxOffDuration},
+	} {
+		if d.d <= 0 {
+			return fmt.Errorf("modemd %s must be greater than zero, got %s", d.name, d.d)
+		}
+	}
+	if md.MinConnDuration < 0 {
+		return fmt.Errorf("modemd min-connection-duration can't be negative, got %s", md.MinConnDuration)
+	}
+
+	// The modem has to be found and connected before the initial on duration
+	// runs out, otherwise it is never given a chance to connect after booting.
+	if md.FindModemTimeout+md.ConnectionTimeout > md.InitialOnDuration {
+		return fmt.Errorf("modemd find-modem-timeout (%s) plus connection-timeout (%s) must not be more than initial-on-duration (%s)",
+			md.FindModemTimeout, md.ConnectionTimeout, md.InitialOnDuration)
+	}
+	if md.TestInterval > md.InitialOnDuration {
+		return fmt.Errorf("modemd test-interval (%s) must not be more than initial-on-duration (%s)",
+			md.TestInterval, md.InitialOnDuration)
+	}
+	if md.MinConnDuration > md.MaxOffDuration {
+		return fmt.Errorf("modemd min-connection-duration (%s) must not be more than max-off-duration (%s)",
+			md.MinConnDuration, md.MaxOffDuration)
+	}
+	// Retrying less often than the maximum off time would leave the modem off for longer than allowed.
+	if md.RetryInterval > md.MaxOffDuration {
+		return fmt.Errorf("modemd retry-interval (%s) must not be more than max-off-duration (%s)",
+			md.RetryInterval, md.MaxOffDuration)
+	}
+	if md.RetryFindModemInterval > md.MaxOffDuration {
+		return fmt.Errorf("modemd retry-find-modem-interval (%s) must not be more than max-off-duration (%s)",
+			md.RetryFindModemInterval, md.MaxOffDuration)
+	}
+	return nil
+}
+
+func validateModems(modems []Modem) error {
+	ids := map[string]string{}
+	names := map[string]bool{}
+	for _, m := range modems {
+		if names[m.Name] {
+			return fmt.Errorf("modem '%s' is given more than once", m.Name)
+		}
+		names[m.Name] = true
+
+		if m.VendorProductID == "" {
+			continue
+		}
+		if !vendorProductIDRegex.MatchString(m.VendorProductID) {
+			return fmt.Errorf("modem '%s' vendor product ID must be 'xxxx:xxxx' in hex, got '%s'", m.Name, m.VendorProductID)
+		}
+		id := strings.ToLower(m.VendorProductID)
+		if other, ok := ids[id]; ok {
+			return fmt.Errorf("modems '%s' and '%s' have the same vendor product ID '%s'", other, m.Name, id)
+		}
+		ids[id] = m.Name
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestModemdValidation(t *testing.T) {
+	require.NoError(t, modemdValidateFunc(DefaultModemd()))
+
+	for name, modify := range map[string]func(m *Modemd){
+		"zero test interval":      func(m *Modemd) { m.TestInterval = 0 },
+		"negative retry interval": func(m *Modemd) { m.RetryInterval = -time.Minute },
+		"negative min connection": func(m *Modemd) { m.MinConnDuration = -time.Second },
+		"min connection over max off": func(m *Modemd) {
+			m.MinConnDuration = 2 * time.Hour
+			m.MaxOffDuration = time.Hour
+			m.RetryInterval = time.Minute
+			m.RetryFindModemInterval = time.Minute
+		},
+		"can't connect in initial on": func(m *Modemd) { m.FindModemTimeout = 18 * time.Minute },
+		"test interval over initial":  func(m *Modemd) { m.TestInterval = time.Hour },
+		"retry over max off":          func(m *Modemd) { m.RetryInterval = 25 * time.Hour },
+		"retry find over max off":     func(m *Modemd) { m.MaxOffDuration = 2 * time.Hour },
+		"vendor product id":           func(m *Modemd) { m.Modems[0].VendorProductID = "12d114db" },
+		"vendor product id not hex":   func(m *Modemd) { m.Modems[0].VendorProductID = "12d1:14dg" },
+		"duplicate vendor product id": func(m *Modemd) { m.Modems[1].VendorProductID = "12D1:14DB" },
+		"duplicate name":              func(m *Modemd) { m.Modems[1].Name = m.Modems[0].Name },
+		"profile vendor product id": func(m *Modemd) {
+			m.Profiles = []ModemProfile{{Name: "p", VendorProductID: "huawei"}}
+		},
+	} {
+		m := DefaultModemd()
+		m.Modems = append([]Modem{}, m.Modems...)
+		modify(&m)
+		require.Error(t, modemdValidateFunc(m), name)
+	}
+
+	require.NoError(t, modemdValidateFunc(map[string]any{"test-interval": "5m", "initial-on-duration": "1h",
+		"find-modem-timeout": "3m", "connection-timeout": "3m", "request-on-duration": "2m",
+		"retry-interval": "20m", "retry-find-modem-interval": "3h", "max-off-duration": "24h"}))
+}
+
+func TestSetInvalidModemd(t *testing.T) {
+	defer newFs(t, "")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	require.Error(t, conf.SetFromMap(ModemdKey, map[string]any{"max-off-duration": "10m"}, false))
+	require.NoError(t, conf.SetFromMap(ModemdKey, map[string]any{"max-off-duration": "12h"}, false))
+	require.Error(t, conf.SetFromMap(ModemdKey, map[string]any{"test-interval": "0s"}, false))
+
+	// Partial maps are checked with the rest of the section as it is in the config.
+	require.Error(t, conf.SetFromMap(ModemdKey, map[string]any{"test-interval": "30m"}, false))
+	require.NoError(t, conf.SetFromMap(ModemdKey, map[string]any{"initial-on-duration": "1h"}, false))
+	require.NoError(t, conf.SetFromMap(ModemdKey, map[string]any{"test-interval": "30m"}, false))
+
+	require.NoError(t, conf.SetFromMap(ModemdKey, map[string]any{"max-off-duration": "4h"}, false))
+	require.Error(t, conf.SetFromMap(ModemdKey, map[string]any{"min-connection-duration": "5h"}, false))
+
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	require.Error(t, conf.SetFromMap(ModemdKey, map[string]any{"min-connection-duration": "5h"}, false))
+	require.NoError(t, conf.SetFromMap(ModemdKey, map[string]any{"min-connection-duration": "2h"}, false))
+}
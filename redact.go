@@ -0,0 +1,197 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// RedactedValue is shown in place of secret values.
+const RedactedValue = "************"
+
+// Any section can mark a field as secret with the `secret:"true"` struct tag.
+// Secret values are replaced by RedactedValue in anything logged, printed or
+// sent as an event.
+
+// Redact returns a copy of the config map, keyed by section, with the values
+// of secret fields replaced. Sections can be maps or structs.
+func Redact(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		if t, ok := sectionType(key); ok {
+			out[key] = redactValue(value, t)
+		} else {
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// IsSecret returns true if the key, such as "secrets.device-password" or
+// "secrets.modem-profiles.spark.pin", is for a secret field.
+func IsSecret(key string) bool {
+	path := strings.Split(key, ".")
+	t, ok := sectionType(path[0])
+	if !ok {
+		return false
+	}
+	for _, name := range path[1:] {
+		t = derefType(t)
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := fieldByName(t, name)
+			if !ok {
+				return isUnknownSecret(t, name)
+			}
+			if isSecretField(f) {
+				return true
+			}
+			t = f.Type
+		case reflect.Map:
+			t = t.Elem()
+		case reflect.Slice, reflect.Array:
+			if _, err := strconv.Atoi(name); err != nil {
+				return false
+			}
+			t = t.Elem()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+func sectionType(key string) (reflect.Type, bool) {
+	section, ok := allSections[strings.ToLower(key)]
+	if !ok || section.pointerValue == nil {
+		return nil, false
+	}
+	return reflect.TypeOf(section.pointerValue()), true
+}
+
+func redactValue(v interface{}, t reflect.Type) interface{} {
+	t = derefType(t)
+	if v == nil || !hasSecrets(t, map[reflect.Type]bool{}) {
+		return v
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			var err error
+			if m, err = interfaceToMap(v); err != nil {
+				// Don't risk showing secrets that couldn't be found.
+				return RedactedValue
+			}
+		}
+		out := make(map[string]interface{}, len(m))
+		for k, val := range m {
+			f, ok := fieldByName(t, k)
+			switch {
+			case !ok && isUnknownSecret(t, k):
+				out[k] = RedactedValue
+			case !ok:
+				out[k] = val
+			case isSecretField(f):
+				out[k] = RedactedValue
+			default:
+				out[k] = redactValue(val, f.Type)
+			}
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return v
+		}
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			out[i] = redactValue(rv.Index(i).Interface(), t.Elem())
+		}
+		return out
+	case reflect.Map:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+			return v
+		}
+		out := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			out[iter.Key().String()] = redactValue(iter.Value().Interface(), t.Elem())
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// isUnknownSecret returns true if the key isn't a field of t but could be a
+// secret, such as from a newer version, because t has secrets. The time a
+// section was updated is never secret.
+func isUnknownSecret(t reflect.Type, key string) bool {
+	return !strings.EqualFold(key, "updated") && hasSecrets(t, map[reflect.Type]bool{})
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// hasSecrets returns true if the type has any secret fields.
+func hasSecrets(t reflect.Type, seen map[reflect.Type]bool) bool {
+	t = derefType(t)
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if isSecretField(t.Field(i)) || hasSecrets(t.Field(i).Type, seen) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return hasSecrets(t.Elem(), seen)
+	}
+	return false
+}
+
+func isSecretField(f reflect.StructField) bool {
+	secret, _ := strconv.ParseBool(f.Tag.Get("secret"))
+	return secret
+}
+
+// fieldByName finds the field by its mapstructure name, ignoring case like
+// mapstructure does.
+func fieldByName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fieldName := strings.Split(f.Tag.Get("mapstructure"), ",")[0]
+		if fieldName == "" {
+			fieldName = f.Name
+		}
+		if strings.EqualFold(fieldName, name) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
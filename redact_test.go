@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testSecretServer struct {
+	Host string `mapstructure:"host"`
+	Key  string `mapstructure:"key" secret:"true"`
+}
+
+type testSecretSection struct {
+	User    string             `mapstructure:"user"`
+	Token   string             `mapstructure:"token" secret:"true"`
+	Servers []testSecretServer `mapstructure:"servers"`
+}
+
+const testSecretKey = "test-secret"
+
+func registerTestSecretSection(t *testing.T) {
+	allSections[testSecretKey] = section{
+		key:          testSecretKey,
+		validate:     noValidateFunc,
+		pointerValue: func() interface{} { return &testSecretSection{} },
+	}
+	t.Cleanup(func() { delete(allSections, testSecretKey) })
+}
+
+func TestRedact(t *testing.T) {
+	registerTestSecretSection(t)
+
+	m := map[string]interface{}{
+		testSecretKey: map[string]interface{}{
+			"user":  "admin",
+			"token": "abc123",
+			"servers": []interface{}{
+				map[string]interface{}{"host": "a.example.com", "key": "key-a"},
+			},
+		},
+		SecretsKey: &Secrets{
+			DevicePassword: "hunter2",
+			ModemProfiles:  map[string]ModemSecrets{"spark": {Password: "sim-password", PIN: "1234"}},
+		},
+		WindowsKey:      map[string]interface{}{"start-recording": "-30m", "unknown": "shown"},
+		"not-a-section": "left alone",
+	}
+	redacted := Redact(m)
+
+	require.Equal(t, map[string]interface{}{
+		"user":  "admin",
+		"token": RedactedValue,
+		"servers": []interface{}{
+			map[string]interface{}{"host": "a.example.com", "key": RedactedValue},
+		},
+	}, redacted[testSecretKey])
+	secrets := redacted[SecretsKey].(map[string]interface{})
+	require.Equal(t, RedactedValue, secrets["device-password"])
+	require.Equal(t, map[string]interface{}{"spark": map[string]interface{}{
+		"password": RedactedValue,
+		"pin":      RedactedValue,
+	}}, secrets["modem-profiles"])
+	require.Equal(t, m[WindowsKey], redacted[WindowsKey])
+	require.Equal(t, "left alone", redacted["not-a-section"])
+
+	// The original map isn't changed.
+	require.Equal(t, "abc123", m[testSecretKey].(map[string]interface{})["token"])
+	for _, secret := range []string{"abc123", "key-a", "hunter2", "sim-password", "1234"} {
+		require.NotContains(t, fmt.Sprintf("%+v", redacted), secret)
+	}
+
+	// Keys that aren't fields of a section with secrets could be secrets.
+	redacted = Redact(map[string]interface{}{SecretsKey: map[string]interface{}{"api-token": "x", "updated": "2024-01-01"}})
+	require.Equal(t, map[string]interface{}{"api-token": RedactedValue, "updated": "2024-01-01"}, redacted[SecretsKey])
+}
+
+func TestIsSecret(t *testing.T) {
+	registerTestSecretSection(t)
+
+	for key, secret := range map[string]bool{
+		"secrets.device-password":          true,
+		"secrets.modem-profiles.spark.pin": true,
+		"secrets.modem-profiles.spark":     false,
+		"test-secret.token":                true,
+		"test-secret.servers.0.key":        true,
+		"test-secret.servers.0.host":       false,
+		"test-secret.servers.first.key":    false,
+		"test-secret.user":                 false,
+		"secrets.api-token":                true,
+		"secrets.updated":                  false,
+		"test-secret.servers.0.port":       true,
+		"windows.start-recording":          false,
+		"not-a-section.token":              false,
+	} {
+		require.Equal(t, secret, IsSecret(key), key)
+	}
+}
+
+func TestNoSecretsInOutput(t *testing.T) {
+	defer newFs(t, "")()
+	registerTestSecretSection(t)
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	require.NoError(t, conf.Set(SecretsKey, Secrets{DevicePassword: "hunter2"}))
+	require.NoError(t, conf.Set(testSecretKey, map[string]interface{}{"user": "admin", "token": "abc123"}))
+
+	event := conf.configEvent()
+	details := fmt.Sprintf("%+v", event.Details)
+	require.NotContains(t, details, "hunter2")
+	require.NotContains(t, details, "abc123")
+	require.Contains(t, details, "admin")
+
+	var ts testSecretSection
+	require.NoError(t, conf.Unmarshal(testSecretKey, &ts))
+	require.Equal(t, "abc123", ts.Token)
+}
@@ -0,0 +1,277 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// Secret values are encrypted with AES-256-GCM before being written to the
+// secrets file, as "enc:v1:<key id>:<base64 nonce and ciphertext>". The key is
+// derived from a line of the key file, or from the machine id if there is no
+// key file. The key file can hold more than one key, the first is used for
+// encrypting and the others are only for decrypting, which lets the key be
+// rotated without losing secrets if interrupted.
+
+const (
+	SecretsKeyFileName = "secrets.key"
+	encryptedPrefix    = "enc:v1:"
+	secretsKeyInfo     = "cacophony go-config secrets"
+	secretsKeyBytes    = 32
+)
+
+// machineIDFile is used for the key when there is no key file.
+var machineIDFile = "/etc/machine-id"
+
+var (
+	ErrSecretsKeyMissing = errors.New("secrets key missing")
+	errBadSecret         = errors.New("could not decrypt secret")
+)
+
+type secretsKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+func newSecretsKey(material []byte) (secretsKey, error) {
+	key, err := hkdf.Key(sha256.New, material, nil, secretsKeyInfo, secretsKeyBytes)
+	if err != nil {
+		return secretsKey{}, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return secretsKey{}, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return secretsKey{}, err
+	}
+	sum := sha256.Sum256(key)
+	return secretsKey{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// The name of the secret is authenticated so values can't be swapped around.
+func (k secretsKey) encrypt(name, value string) (string, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := k.aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	return encryptedPrefix + k.id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptSecret(keys []secretsKey, name, value string) (string, error) {
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if !ok {
+		return "", fmt.Errorf("%w '%s': bad format", errBadSecret, name)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("%w '%s': %v", errBadSecret, name, err)
+	}
+	for _, k := range keys {
+		if k.id != id {
+			continue
+		}
+		if len(sealed) < k.aead.NonceSize() {
+			return "", fmt.Errorf("%w '%s': too short", errBadSecret, name)
+		}
+		nonce, ciphertext := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+		plain, err := k.aead.Open(nil, nonce, ciphertext, []byte(name))
+		if err != nil {
+			return "", fmt.Errorf("%w '%s': %v", errBadSecret, name, err)
+		}
+		return string(plain), nil
+	}
+	return "", fmt.Errorf("%w: no key with id '%s' for secret '%s'", ErrSecretsKeyMissing, id, name)
+}
+
+// mapSecrets replaces each string in the nested map m with the result of f.
+func mapSecrets(m map[string]interface{}, prefix string, f func(name, value string) (string, error)) error {
+	for k, v := range m {
+		name := prefix + k
+		switch v := v.(type) {
+		case string:
+			s, err := f(name, v)
+			if err != nil {
+				return err
+			}
+			m[k] = s
+		case map[string]interface{}:
+			if err := mapSecrets(v, name+".", f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encryptSecrets encrypts all the strings in m with the key.
+func encryptSecrets(m map[string]interface{}, key secretsKey) error {
+	return mapSecrets(m, "", key.encrypt)
+}
+
+// decryptSecrets decrypts all the strings in m. It returns true if any were
+// not encrypted, such as from before secrets were encrypted.
+func decryptSecrets(m map[string]interface{}, keys []secretsKey) (bool, error) {
+	plaintext := false
+	err := mapSecrets(m, "", func(name, value string) (string, error) {
+		if !strings.HasPrefix(value, encryptedPrefix) {
+			plaintext = true
+			return value, nil
+		}
+		return decryptSecret(keys, name, value)
+	})
+	return plaintext, err
+}
+
+// secretsKeyFile returns the key file, which is next to the secrets file.
+func (c *Config) secretsKeyFile() string {
+	return path.Join(path.Dir(c.secrets.ConfigFileUsed()), SecretsKeyFileName)
+}
+
+// secretsKeys returns the keys from the key file, or the key from the machine
+// id if there is no key file. The first key is for encrypting.
+func (c *Config) secretsKeys() ([]secretsKey, error) {
+	materials, err := readSecretsKeyFile(c.secretsKeyFile())
+	if err != nil {
+		return nil, err
+	}
+	if len(materials) == 0 {
+		if materials, err = machineIDKeyMaterial(); err != nil {
+			return nil, err
+		}
+	}
+	keys := []secretsKey{}
+	for _, material := range materials {
+		k, err := newSecretsKey([]byte(material))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// encryptingKey returns the key to encrypt secrets with, making a key file if
+// there is no key.
+func (c *Config) encryptingKey() (secretsKey, error) {
+	keys, err := c.secretsKeys()
+	if err != nil {
+		return secretsKey{}, err
+	}
+	if len(keys) > 0 {
+		return keys[0], nil
+	}
+	material, err := newSecretsKeyMaterial()
+	if err != nil {
+		return secretsKey{}, err
+	}
+	if err := writeFileAtomic(c.secretsKeyFile(), []byte(material+"\n"), secretsFilePerms); err != nil {
+		return secretsKey{}, err
+	}
+	return newSecretsKey([]byte(material))
+}
+
+// RotateSecretsKey encrypts the secrets with a new key and removes the old
+// keys from the key file.
+func (c *Config) RotateSecretsKey() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.getFileLock(); err != nil {
+		return err
+	}
+	defer c.fileLock.Unlock()
+	if c.secretsErr != nil {
+		return c.secretsErr
+	}
+
+	keyFile := c.secretsKeyFile()
+	old, err := readSecretsKeyFile(keyFile)
+	if err != nil {
+		return err
+	}
+	if len(old) == 0 {
+		// Without a key file the secrets are encrypted with the machine id.
+		if old, err = machineIDKeyMaterial(); err != nil {
+			return err
+		}
+	}
+	material, err := newSecretsKeyMaterial()
+	if err != nil {
+		return err
+	}
+	// Keep the old keys until the secrets have been encrypted with the new one.
+	keys := append([]string{material}, old...)
+	if err := writeFileAtomic(keyFile, []byte(strings.Join(keys, "\n")+"\n"), secretsFilePerms); err != nil {
+		return err
+	}
+	if err := c.writeSecrets(); err != nil {
+		return err
+	}
+	return writeFileAtomic(keyFile, []byte(material+"\n"), secretsFilePerms)
+}
+
+func readSecretsKeyFile(keyFile string) ([]string, error) {
+	b, err := afero.ReadFile(fs, keyFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	materials := []string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			materials = append(materials, line)
+		}
+	}
+	return materials, nil
+}
+
+// machineIDKeyMaterial returns the machine id for the key, or nothing if there
+// is no machine id.
+func machineIDKeyMaterial() ([]string, error) {
+	machineID, err := afero.ReadFile(fs, machineIDFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if id := strings.TrimSpace(string(machineID)); id != "" {
+		return []string{id}, nil
+	}
+	return nil, nil
+}
+
+func newSecretsKeyMaterial() (string, error) {
+	b := make([]byte, secretsKeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+var testSecretsKeyFile = path.Join(DefaultConfigDir, SecretsKeyFileName)
+
+func setTestSecrets(t *testing.T) *Config {
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	require.NoError(t, conf.Set(SecretsKey, Secrets{
+		DevicePassword: "hunter2",
+		ModemProfiles:  map[string]ModemSecrets{"spark": {PIN: "1234"}},
+	}))
+	return conf
+}
+
+func readTestFile(t *testing.T, file string) string {
+	b, err := afero.ReadFile(fs, file)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestSecretsEncrypted(t *testing.T) {
+	defer newFs(t, "")()
+	setTestSecrets(t)
+
+	secretsFile := readTestFile(t, testSecretsFile)
+	require.NotContains(t, secretsFile, "hunter2")
+	require.NotContains(t, secretsFile, "1234")
+	require.Equal(t, 4, strings.Count(secretsFile, encryptedPrefix)) // Including the empty modem password and the password hash
+	info, err := fs.Stat(testSecretsKeyFile)
+	require.NoError(t, err)
+	require.Equal(t, secretsFilePerms, info.Mode().Perm())
+
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	password, err := conf.Secret("device-password")
+	require.NoError(t, err)
+	require.Equal(t, "hunter2", password)
+	var secrets Secrets
+	require.NoError(t, conf.Unmarshal(SecretsKey, &secrets))
+	require.Equal(t, "1234", secrets.ModemProfiles["spark"].PIN)
+
+	// Encrypted values can't be moved to another secret.
+	pin := strings.Split(strings.Split(secretsFile, `pin = "`)[1], `"`)[0]
+	tampered := strings.Replace(secretsFile, strings.Split(strings.Split(secretsFile, `device-password = "`)[1], `"`)[0], pin, 1)
+	require.NoError(t, afero.WriteFile(fs, testSecretsFile, []byte(tampered), secretsFilePerms))
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	_, err = conf.Secret("device-password")
+	require.ErrorIs(t, err, errBadSecret)
+}
+
+func TestSecretsPlaintextEncryptedOnRead(t *testing.T) {
+	defer newFs(t, "")()
+	require.NoError(t, afero.WriteFile(fs, testSecretsFile, []byte("[secrets]\ndevice-password = \"hunter2\"\n"), 0644))
+
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	password, err := conf.Secret("device-password")
+	require.NoError(t, err)
+	require.Equal(t, "hunter2", password)
+	require.NotContains(t, readTestFile(t, testSecretsFile), "hunter2")
+}
+
+func TestSecretsMachineIDKey(t *testing.T) {
+	defer newFs(t, "")()
+	require.NoError(t, afero.WriteFile(fs, machineIDFile, []byte("0123456789abcdef0123456789abcdef\n"), 0444))
+	setTestSecrets(t)
+
+	exists, err := afero.Exists(fs, testSecretsKeyFile)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	password, err := conf.Secret("device-password")
+	require.NoError(t, err)
+	require.Equal(t, "hunter2", password)
+
+	// A different machine can't decrypt them.
+	require.NoError(t, afero.WriteFile(fs, machineIDFile, []byte("fedcba9876543210fedcba9876543210\n"), 0444))
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	_, err = conf.Secret("device-password")
+	require.ErrorIs(t, err, ErrSecretsKeyMissing)
+}
+
+func TestSecretsKeyMissing(t *testing.T) {
+	defer newFs(t, "")()
+	setTestSecrets(t)
+	require.NoError(t, fs.Remove(testSecretsKeyFile))
+
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	_, err = conf.Secret("device-password")
+	require.ErrorIs(t, err, ErrSecretsKeyMissing)
+	var secrets Secrets
+	require.ErrorIs(t, conf.Unmarshal(SecretsKey, &secrets), ErrSecretsKeyMissing)
+	require.Empty(t, secrets.DevicePassword)
+
+	// The rest of the config is still usable, but the secrets can't be overwritten.
+	require.NoError(t, conf.Set(WindowsKey, DefaultWindows()))
+	require.ErrorIs(t, conf.Set(SecretsKey, Secrets{DevicePassword: "new"}), ErrSecretsKeyMissing)
+	require.ErrorIs(t, conf.RotateSecretsKey(), ErrSecretsKeyMissing)
+}
+
+func TestRotateSecretsKey(t *testing.T) {
+	defer newFs(t, "")()
+	conf := setTestSecrets(t)
+	oldKey := readTestFile(t, testSecretsKeyFile)
+	oldSecrets := readTestFile(t, testSecretsFile)
+
+	require.NoError(t, conf.RotateSecretsKey())
+	newKey := readTestFile(t, testSecretsKeyFile)
+	require.NotEqual(t, oldKey, newKey)
+	require.Equal(t, 1, strings.Count(newKey, "\n"))
+	require.NotEqual(t, oldSecrets, readTestFile(t, testSecretsFile))
+
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	password, err := conf.Secret("device-password")
+	require.NoError(t, err)
+	require.Equal(t, "hunter2", password)
+
+	// Secrets encrypted with an old key can be read while it is still in the key file.
+	require.NoError(t, afero.WriteFile(fs, testSecretsFile, []byte(oldSecrets), secretsFilePerms))
+	require.NoError(t, afero.WriteFile(fs, testSecretsKeyFile, []byte(newKey+oldKey), secretsFilePerms))
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	pin, err := conf.Secret("modem-profiles.spark.pin")
+	require.NoError(t, err)
+	require.Equal(t, "1234", pin)
+}
+
+// failRenameFs fails to rename to the file.
+type failRenameFs struct {
+	afero.Fs
+	file string
+}
+
+func (f failRenameFs) Rename(oldname, newname string) error {
+	if newname == f.file {
+		return errors.New("rename failed")
+	}
+	return f.Fs.Rename(oldname, newname)
+}
+
+func TestRotateSecretsKeyFromMachineID(t *testing.T) {
+	defer newFs(t, "")()
+	require.NoError(t, afero.WriteFile(fs, machineIDFile, []byte("0123456789abcdef0123456789abcdef\n"), 0444))
+	conf := setTestSecrets(t)
+
+	// Interrupted before the secrets are written with the new key.
+	memFs := fs
+	fs = failRenameFs{Fs: memFs, file: testSecretsFile}
+	require.Error(t, conf.RotateSecretsKey())
+	fs = memFs
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	pin, err := conf.Secret("modem-profiles.spark.pin")
+	require.NoError(t, err)
+	require.Equal(t, "1234", pin)
+
+	require.NoError(t, conf.RotateSecretsKey())
+	require.Equal(t, 1, strings.Count(readTestFile(t, testSecretsKeyFile), "\n"))
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	pin, err = conf.Secret("modem-profiles.spark.pin")
+	require.NoError(t, err)
+	require.Equal(t, "1234", pin)
+}
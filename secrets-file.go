@@ -0,0 +1,190 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+const (
+	SecretsFileName  = "secrets.toml"
+	secretsFilePerms = os.FileMode(0600)
+)
+
+var ErrSecretNotFound = errors.New("secret not found")
+
+// The secrets section is kept in its own file, only readable by its owner, so
+// secret values are never in config.toml or the values and events made from it.
+
+// Secret returns a value from the secrets section, such as "device-password"
+// or "modem-profiles.<profile>.pin".
+func (c *Config) Secret(key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.secret(key)
+}
+
+func (c *Config) secret(key string) (string, error) {
+	if c.secretsErr != nil {
+		return "", c.secretsErr
+	}
+	fullKey := SecretsKey + "." + key
+	if !c.secrets.IsSet(fullKey) {
+		return "", fmt.Errorf("%w: '%s'", ErrSecretNotFound, key)
+	}
+	return c.secrets.GetString(fullKey), nil
+}
+
+// isSecretKey returns true if the key is in the secrets section.
+func isSecretKey(key string) bool {
+	return strings.Split(key, ".")[0] == SecretsKey
+}
+
+// viperFor returns where the key is stored.
+func (c *Config) viperFor(key string) *viper.Viper {
+	if isSecretKey(key) {
+		return c.secrets
+	}
+	return c.v
+}
+
+// readInSecrets reads and decrypts the secrets file, which doesn't exist until
+// a secret has been set. If the secrets can't be read or decrypted the error
+// is kept for when they are used, so the rest of the config can still be read.
+func (c *Config) readInSecrets() {
+	plaintext, err := c.readSecretsFile()
+	c.secretsErr = err
+	if err == nil && plaintext {
+		// Left as plaintext if it can't be written, until the secrets are next written.
+		_ = c.writeSecrets()
+	}
+}
+
+// readSecretsFile reads the secrets file into c.secrets, returning true if
+// any secrets weren't encrypted.
+func (c *Config) readSecretsFile() (bool, error) {
+	b, err := afero.ReadFile(fs, c.secrets.ConfigFileUsed())
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	tree, err := toml.LoadBytes(b)
+	if err != nil {
+		return false, err
+	}
+	m := tree.ToMap()
+	keys, err := c.secretsKeys()
+	if err != nil {
+		return false, err
+	}
+	plaintext, err := decryptSecrets(m, keys)
+	if err != nil {
+		return false, err
+	}
+	return plaintext, readSecretsMap(c.secrets, m)
+}
+
+func readSecretsMap(v *viper.Viper, m map[string]interface{}) error {
+	tree, err := toml.TreeFromMap(m)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if _, err := tree.WriteTo(&buf); err != nil {
+		return err
+	}
+	return v.ReadConfig(&buf)
+}
+
+// migrateSecrets moves a secrets section from config.toml, as written by older
+// versions, into the secrets file. Values already in the secrets file are kept.
+func (c *Config) migrateSecrets() error {
+	old, ok := c.v.Get(SecretsKey).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	// Leave them in the config until the secrets file can be decrypted.
+	if c.secretsErr != nil {
+		return nil
+	}
+	for k, val := range old {
+		if key := SecretsKey + "." + k; !c.secrets.IsSet(key) {
+			c.secrets.Set(key, val)
+		}
+	}
+	// If the files can't be written, such as on a read only file system, the
+	// secrets are left in the config to be moved next time.
+	if err := c.writeSecrets(); err != nil {
+		return nil
+	}
+	v, err := withoutKey(c.v, []string{SecretsKey})
+	if err != nil {
+		return err
+	}
+	if err := v.WriteConfig(); err != nil {
+		return nil
+	}
+	c.v = v
+	return nil
+}
+
+// writeSecrets encrypts the secrets and writes them to a file only readable
+// by its owner, even if it was created with other permissions.
+func (c *Config) writeSecrets() error {
+	if c.secretsErr != nil {
+		return c.secretsErr
+	}
+	// Secrets from before the password was hashed get a hash.
+	if err := c.updatePasswordHash(c.passwordAndHash()); err != nil {
+		return err
+	}
+	file := c.secrets.ConfigFileUsed()
+	settings := c.secrets.AllSettings()
+	exists, err := afero.Exists(fs, file)
+	if err != nil {
+		return err
+	}
+	if !exists && len(settings) == 0 {
+		return nil
+	}
+	key, err := c.encryptingKey()
+	if err != nil {
+		return err
+	}
+	if err := encryptSecrets(settings, key); err != nil {
+		return err
+	}
+	tree, err := toml.TreeFromMap(settings)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if _, err := tree.WriteTo(&buf); err != nil {
+		return err
+	}
+	return writeFileAtomic(file, buf.Bytes(), secretsFilePerms)
+}
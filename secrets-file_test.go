@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testConfigFile  = path.Join(DefaultConfigDir, ConfigFileName)
+	testSecretsFile = path.Join(DefaultConfigDir, SecretsFileName)
+)
+
+func TestMigratingSecrets(t *testing.T) {
+	defer newFs(t, "./test-files/test.toml")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	password, err := conf.Secret("device-password")
+	require.NoError(t, err)
+	require.Equal(t, "pass", password)
+
+	configBytes, err := afero.ReadFile(fs, testConfigFile)
+	require.NoError(t, err)
+	require.NotContains(t, string(configBytes), "[secrets]")
+	require.NotContains(t, string(configBytes), "pass")
+	// Other sections are kept.
+	require.Contains(t, string(configBytes), "[thermal-recorder]")
+
+	secretsBytes, err := afero.ReadFile(fs, testSecretsFile)
+	require.NoError(t, err)
+	require.Contains(t, string(secretsBytes), "device-password")
+	require.NotContains(t, string(secretsBytes), `"pass"`)
+	info, err := fs.Stat(testSecretsFile)
+	require.NoError(t, err)
+	require.Equal(t, secretsFilePerms, info.Mode().Perm())
+
+	// Reading it again doesn't change anything.
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	password, err = conf.Secret("device-password")
+	require.NoError(t, err)
+	require.Equal(t, "pass", password)
+
+	_, err = conf.Secret("not-a-secret")
+	require.ErrorIs(t, err, ErrSecretNotFound)
+}
+
+func TestMigratingSecretsKeyMissing(t *testing.T) {
+	defer newFs(t, "")()
+	setTestSecrets(t)
+	require.NoError(t, fs.Remove(testSecretsKeyFile))
+	require.NoError(t, afero.WriteFile(fs, testConfigFile, []byte("[secrets]\ndevice-password = \"pass\"\n"), 0644))
+
+	// The secrets stay in the config until the secrets file can be decrypted.
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	_, err = conf.Secret("device-password")
+	require.ErrorIs(t, err, ErrSecretsKeyMissing)
+	require.Contains(t, readTestFile(t, testConfigFile), "[secrets]")
+}
+
+func TestMigratingSecretsReadOnly(t *testing.T) {
+	defer newFs(t, "./test-files/test.toml")()
+	fs = afero.NewReadOnlyFs(fs)
+
+	// The secrets are used from the config until they can be moved.
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	password, err := conf.Secret("device-password")
+	require.NoError(t, err)
+	require.Equal(t, "pass", password)
+	require.Contains(t, readTestFile(t, testConfigFile), "[secrets]")
+	require.NotNil(t, conf.Get(ThermalRecorderKey))
+}
+
+// failOpenFs isn't allowed to open the file, like a file owned by another user.
+type failOpenFs struct {
+	afero.Fs
+	file string
+}
+
+func (f failOpenFs) Open(name string) (afero.File, error) {
+	if name == f.file {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	return f.Fs.Open(name)
+}
+
+func TestSecretsUnreadable(t *testing.T) {
+	defer newFs(t, "./test-files/test.toml")()
+	setTestSecrets(t)
+	fs = failOpenFs{Fs: fs, file: testSecretsFile}
+
+	// Everything but the secrets can still be read and set.
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	_, err = conf.Secret("device-password")
+	require.ErrorIs(t, err, os.ErrPermission)
+	require.ErrorIs(t, conf.Set(SecretsKey, Secrets{DevicePassword: "new"}), os.ErrPermission)
+	require.NotNil(t, conf.Get(ThermalRecorderKey))
+	require.NoError(t, conf.Set(WindowsKey, DefaultWindows()))
+}
+
+func TestSecretsNotInConfig(t *testing.T) {
+	defer newFs(t, "")()
+	// A secrets file made with the wrong permissions is fixed on write.
+	require.NoError(t, afero.WriteFile(fs, testSecretsFile, []byte{}, 0644))
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	require.NoError(t, conf.Set(SecretsKey, Secrets{
+		DevicePassword: "hunter2",
+		ModemProfiles:  map[string]ModemSecrets{"spark": {Password: "sim-password", PIN: "1234"}},
+	}))
+	require.NoError(t, conf.Set(WindowsKey, DefaultWindows()))
+
+	configBytes, err := afero.ReadFile(fs, testConfigFile)
+	require.NoError(t, err)
+	for _, secret := range []string{"hunter2", "sim-password", "1234"} {
+		require.NotContains(t, string(configBytes), secret)
+		require.NotContains(t, fmt.Sprint(conf.v.AllSettings()), secret)
+	}
+	info, err := fs.Stat(testSecretsFile)
+	require.NoError(t, err)
+	require.Equal(t, secretsFilePerms, info.Mode().Perm())
+
+	values, err := conf.GetAllValues()
+	require.NoError(t, err)
+	require.NotContains(t, values, SecretsKey)
+	require.NotContains(t, fmt.Sprintf("%+v", values), "hunter2")
+
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	pin, err := conf.Secret("modem-profiles.spark.pin")
+	require.NoError(t, err)
+	require.Equal(t, "1234", pin)
+	var secrets Secrets
+	require.NoError(t, conf.Unmarshal(SecretsKey, &secrets))
+	require.Equal(t, "hunter2", secrets.DevicePassword)
+
+	require.NoError(t, conf.Unset(SecretsKey+".device-password"))
+	_, err = conf.Secret("device-password")
+	require.ErrorIs(t, err, ErrSecretNotFound)
+	pin, err = conf.Secret("modem-profiles.spark.pin")
+	require.NoError(t, err)
+	require.Equal(t, "1234", pin)
+}
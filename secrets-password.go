@@ -0,0 +1,136 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The device password has to be kept as it is sent to the API server, so a
+// PBKDF2-SHA256 hash of it is kept next to it for checking passwords against
+// locally, as "$pbkdf2-sha256$<iterations>$<base64 salt>$<base64 hash>".
+const (
+	passwordHashPrefix     = "$pbkdf2-sha256$"
+	passwordHashIterations = 600000
+	passwordSaltBytes      = 16
+	passwordHashBytes      = 32
+)
+
+var passwordEncoding = base64.RawStdEncoding
+
+const (
+	devicePasswordKey     = SecretsKey + ".device-password"
+	devicePasswordHashKey = SecretsKey + ".device-password-hash"
+)
+
+// SetPassword sets the device password and a salted hash of it.
+func (s *Secrets) SetPassword(plain string) error {
+	hash, err := hashPassword(plain)
+	if err != nil {
+		return err
+	}
+	s.DevicePassword = plain
+	s.DevicePasswordHash = hash
+	return nil
+}
+
+// VerifyPassword returns true if plain is the device password. It is checked
+// against the hash if there is one, otherwise the password is compared directly.
+func (s Secrets) VerifyPassword(plain string) bool {
+	if s.DevicePasswordHash == "" {
+		return s.DevicePassword != "" && subtle.ConstantTimeCompare([]byte(s.DevicePassword), []byte(plain)) == 1
+	}
+	iterations, salt, hash, err := parsePasswordHash(s.DevicePasswordHash)
+	if err != nil {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, plain, salt, iterations, len(hash))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, hash) == 1
+}
+
+// passwordAndHash returns the device password and its hash from the secrets.
+func (c *Config) passwordAndHash() (string, string) {
+	return c.secrets.GetString(devicePasswordKey), c.secrets.GetString(devicePasswordHashKey)
+}
+
+// updatePasswordHash keeps the device password hash matching the password
+// however it was set, such as from a map by the sync service. As checking the
+// hash is slow it is only checked if the password or hash changed from the
+// old ones. A missing hash is added and one without a password is removed.
+func (c *Config) updatePasswordHash(oldPassword, oldHash string) error {
+	password, hash := c.passwordAndHash()
+	switch {
+	case password == "":
+		if hash == "" {
+			return nil
+		}
+		return c.unset(devicePasswordHashKey)
+	case hash == "":
+	case password == oldPassword && hash == oldHash:
+		return nil
+	case Secrets{DevicePasswordHash: hash}.VerifyPassword(password):
+		return nil
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	c.secrets.Set(devicePasswordHashKey, hash)
+	return nil
+}
+
+func hashPassword(plain string) (string, error) {
+	salt := make([]byte, passwordSaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, plain, salt, passwordHashIterations, passwordHashBytes)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%d$%s$%s", passwordHashPrefix, passwordHashIterations, passwordEncoding.EncodeToString(salt), passwordEncoding.EncodeToString(key)), nil
+}
+
+func parsePasswordHash(s string) (iterations int, salt, hash []byte, err error) {
+	if !strings.HasPrefix(s, passwordHashPrefix) {
+		return 0, nil, nil, fmt.Errorf("password hash should start with '%s'", passwordHashPrefix)
+	}
+	parts := strings.Split(strings.TrimPrefix(s, passwordHashPrefix), "$")
+	if len(parts) != 3 {
+		return 0, nil, nil, fmt.Errorf("password hash should have 3 parts, got %d", len(parts))
+	}
+	if iterations, err = strconv.Atoi(parts[0]); err != nil || iterations < 1 {
+		return 0, nil, nil, fmt.Errorf("bad password hash iterations '%s'", parts[0])
+	}
+	if salt, err = passwordEncoding.DecodeString(parts[1]); err != nil {
+		return 0, nil, nil, fmt.Errorf("bad password hash salt: %w", err)
+	}
+	if hash, err = passwordEncoding.DecodeString(parts[2]); err != nil || len(hash) == 0 {
+		return 0, nil, nil, fmt.Errorf("bad password hash: %v", err)
+	}
+	return iterations, salt, hash, nil
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordHashing(t *testing.T) {
+	var s Secrets
+	require.False(t, s.VerifyPassword(""))
+
+	require.NoError(t, s.SetPassword("hunter2"))
+	require.Equal(t, "hunter2", s.DevicePassword)
+	require.True(t, strings.HasPrefix(s.DevicePasswordHash, passwordHashPrefix))
+	require.NotContains(t, s.DevicePasswordHash, "hunter2")
+	require.True(t, s.VerifyPassword("hunter2"))
+	require.False(t, s.VerifyPassword("hunter3"))
+	require.False(t, s.VerifyPassword(""))
+	require.NoError(t, secretsValidateFunc(s))
+
+	// The salt makes each hash different.
+	s2 := Secrets{}
+	require.NoError(t, s2.SetPassword("hunter2"))
+	require.NotEqual(t, s.DevicePasswordHash, s2.DevicePasswordHash)
+
+	// Without a hash the password is compared directly.
+	require.True(t, Secrets{DevicePassword: "pass"}.VerifyPassword("pass"))
+	require.False(t, Secrets{DevicePassword: "pass"}.VerifyPassword("pas"))
+
+	for _, bad := range []string{
+		"hunter2",
+		passwordHashPrefix + "600000$c2FsdA",
+		passwordHashPrefix + "many$c2FsdA$aGFzaA",
+		passwordHashPrefix + "1000$!!$aGFzaA",
+		passwordHashPrefix + "1000$c2FsdA$",
+	} {
+		require.Error(t, secretsValidateFunc(Secrets{DevicePasswordHash: bad}), bad)
+		require.False(t, Secrets{DevicePasswordHash: bad}.VerifyPassword(""), bad)
+	}
+}
+
+func TestDevicePasswordKeptOnWrite(t *testing.T) {
+	defer newFs(t, "./test-files/test.toml")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	// The password is sent to the API server so it is kept, with a hash added on migrating.
+	var secrets Secrets
+	require.NoError(t, conf.Unmarshal(SecretsKey, &secrets))
+	require.Equal(t, "pass", secrets.DevicePassword)
+	require.True(t, strings.HasPrefix(secrets.DevicePasswordHash, passwordHashPrefix))
+	require.True(t, secrets.VerifyPassword("pass"))
+
+	require.NoError(t, secrets.SetPassword("new-password"))
+	require.NoError(t, conf.Set(SecretsKey, secrets))
+	require.NoError(t, conf.Set(WindowsKey, DefaultWindows()))
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	var secrets2 Secrets
+	require.NoError(t, conf.Unmarshal(SecretsKey, &secrets2))
+	require.Equal(t, "new-password", secrets2.DevicePassword)
+	require.Equal(t, secrets.DevicePasswordHash, secrets2.DevicePasswordHash)
+	require.True(t, secrets2.VerifyPassword("new-password"))
+}
+
+func TestDevicePasswordHashFollowsPassword(t *testing.T) {
+	defer newFs(t, "")()
+	conf := setTestSecrets(t)
+	var secrets Secrets
+	require.NoError(t, conf.Unmarshal(SecretsKey, &secrets))
+	require.True(t, secrets.VerifyPassword("hunter2"))
+
+	// Such as the sync service setting the password from the API server.
+	require.NoError(t, conf.SetFromMap(SecretsKey, map[string]interface{}{"device-password": "new"}, true))
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	require.NoError(t, conf.Unmarshal(SecretsKey, &secrets))
+	require.Equal(t, "new", secrets.DevicePassword)
+	require.True(t, secrets.VerifyPassword("new"))
+	require.False(t, secrets.VerifyPassword("hunter2"))
+
+	// A hash that doesn't match the password is replaced.
+	stale := secrets.DevicePasswordHash
+	require.NoError(t, conf.Set(SecretsKey, Secrets{DevicePassword: "newer", DevicePasswordHash: stale}))
+	require.NoError(t, conf.Unmarshal(SecretsKey, &secrets))
+	require.NotEqual(t, stale, secrets.DevicePasswordHash)
+	require.True(t, secrets.VerifyPassword("newer"))
+
+	// Without a password there is no hash.
+	require.NoError(t, conf.SetFromMap(SecretsKey, map[string]interface{}{"device-password": ""}, true))
+	var cleared Secrets
+	require.NoError(t, conf.Unmarshal(SecretsKey, &cleared))
+	require.Empty(t, cleared.DevicePasswordHash)
+	require.False(t, cleared.VerifyPassword(""))
+	require.False(t, cleared.VerifyPassword("newer"))
+}
@@ -16,18 +16,92 @@
 
 package config
 
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"github.com/mitchellh/mapstructure"
+)
+
 const SecretsKey = "secrets"
 
 func init() {
 	allSections[SecretsKey] = section{
 		key:         SecretsKey,
 		mapToStruct: secretsMapToStruct,
-		validate:    noValidateFunc,
+		validate:    secretsValidateFunc,
+		pointerValue: func() interface{} {
+			return &Secrets{}
+		},
 	}
+	allSectionDecodeHookFuncs = append(allSectionDecodeHookFuncs, secretsToMap)
 }
 
 type Secrets struct {
-	DevicePassword string `mapstructure:"device-password"`
+	DevicePassword     string                  `mapstructure:"device-password" secret:"true"`                // Sent to the API server to authenticate the device
+	DevicePasswordHash string                  `mapstructure:"device-password-hash,omitempty" secret:"true"` // For checking a password against, see SetPassword
+	ModemProfiles      map[string]ModemSecrets `mapstructure:"modem-profiles"`                               // Keyed by modem profile name
+}
+
+// ModemSecrets are the credentials for a modem profile in the modemd section
+type ModemSecrets struct {
+	Password string `mapstructure:"password" secret:"true"`
+	PIN      string `mapstructure:"pin" secret:"true"` // SIM PIN
+}
+
+var simPINRegex = regexp.MustCompile(`^[0-9]{4,8}$`)
+
+// ModemSecrets returns the credentials for the named modem profile.
+func (s Secrets) ModemSecrets(profile string) (ModemSecrets, bool) {
+	ms, ok := s.ModemProfiles[profile]
+	return ms, ok
+}
+
+func secretsValidateFunc(secrets any) error {
+	s, err := ConvertToStruct[Secrets](secrets)
+	if err != nil {
+		return err
+	}
+	if s.DevicePasswordHash != "" {
+		if _, _, _, err := parsePasswordHash(s.DevicePasswordHash); err != nil {
+			return fmt.Errorf("device-password-hash: %w", err)
+		}
+	}
+	for name, ms := range s.ModemProfiles {
+		if ms.PIN != "" && !simPINRegex.MatchString(ms.PIN) {
+			return fmt.Errorf("SIM PIN for modem profile '%s' must be 4 to 8 digits", name)
+		}
+	}
+	return nil
+}
+
+func secretsToMap(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if t != mapStrInterfaceType {
+		return data, nil
+	}
+	switch f {
+	case reflect.TypeOf(&Secrets{}):
+		data = *(data.(*Secrets)) // follow the pointer
+		fallthrough
+	case reflect.TypeOf(Secrets{}):
+		m := map[string]interface{}{}
+		if err := mapstructure.Decode(data, &m); err != nil {
+			return nil, err
+		}
+		modemProfiles := map[string]interface{}{}
+		for name, ms := range data.(Secrets).ModemProfiles {
+			msMap := map[string]interface{}{}
+			if err := mapstructure.Decode(ms, &msMap); err != nil {
+				return nil, err
+			}
+			modemProfiles[name] = msMap
+		}
+		m["modem-profiles"] = modemProfiles
+		return m, nil
+	default:
+		return data, nil
+	}
 }
 
 func secretsMapToStruct(m map[string]interface{}) (interface{}, error) {
@@ -0,0 +1,65 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	julianUnixEpoch = 2440587.5
+	julian2000      = 2451545.0
+)
+
+// SunTimes returns the sunrise and sunset on the date of day, in its location,
+// at the latitude and longitude. It returns false if the sun doesn't rise or
+// set that day. Times are accurate to within a few minutes, using the sunrise
+// equation.
+func SunTimes(day time.Time, latitude, longitude float64) (sunrise, sunset time.Time, ok bool) {
+	localNoon := time.Date(day.Year(), day.Month(), day.Day(), 12, 0, 0, 0, day.Location())
+	n := math.Round(unixToJulian(localNoon) - julian2000 + longitude/360)
+
+	meanNoon := n - longitude/360
+	anomaly := degToRad(math.Mod(357.5291+0.98560028*meanNoon, 360))
+	center := 1.9148*math.Sin(anomaly) + 0.02*math.Sin(2*anomaly) + 0.0003*math.Sin(3*anomaly)
+	eclipticLong := degToRad(math.Mod(radToDeg(anomaly)+center+180+102.9372, 360))
+	transit := julian2000 + meanNoon + 0.0053*math.Sin(anomaly) - 0.0069*math.Sin(2*eclipticLong)
+
+	declination := math.Asin(math.Sin(eclipticLong) * math.Sin(degToRad(23.4397)))
+	lat := degToRad(latitude)
+	cosHourAngle := (math.Sin(degToRad(-0.833)) - math.Sin(lat)*math.Sin(declination)) /
+		(math.Cos(lat) * math.Cos(declination))
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hourAngle := radToDeg(math.Acos(cosHourAngle)) / 360
+
+	loc := day.Location()
+	return julianToTime(transit - hourAngle).In(loc), julianToTime(transit + hourAngle).In(loc), true
+}
+
+func unixToJulian(t time.Time) float64 {
+	return float64(t.Unix())/86400 + julianUnixEpoch
+}
+
+func julianToTime(j float64) time.Time {
+	return time.Unix(0, int64((j-julianUnixEpoch)*86400*float64(time.Second))).Truncate(time.Second)
+}
+
+func degToRad(d float64) float64 { return d * math.Pi / 180 }
+func radToDeg(r float64) float64 { return r * 180 / math.Pi }
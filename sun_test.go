@@ -0,0 +1,56 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSunTimes(t *testing.T) {
+	nz, err := time.LoadLocation("Pacific/Auckland")
+	require.NoError(t, err)
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+	chch := DefaultWindowLocation()
+
+	tests := []struct {
+		day       time.Time
+		lat, long float64
+		sunrise   time.Time
+		sunset    time.Time
+	}{
+		{
+			day:     time.Date(2024, 1, 1, 0, 0, 0, 0, nz),
+			lat:     float64(chch.Latitude),
+			long:    float64(chch.Longitude),
+			sunrise: time.Date(2024, 1, 1, 5, 52, 0, 0, nz),
+			sunset:  time.Date(2024, 1, 1, 21, 13, 0, 0, nz),
+		},
+		{
+			day:     time.Date(2024, 6, 21, 15, 0, 0, 0, nz),
+			lat:     float64(chch.Latitude),
+			long:    float64(chch.Longitude),
+			sunrise: time.Date(2024, 6, 21, 8, 2, 0, 0, nz),
+			sunset:  time.Date(2024, 6, 21, 16, 59, 0, 0, nz),
+		},
+		{
+			day:     time.Date(2024, 6, 21, 23, 0, 0, 0, london),
+			lat:     51.5074,
+			long:    -0.1278,
+			sunrise: time.Date(2024, 6, 21, 4, 43, 0, 0, london),
+			sunset:  time.Date(2024, 6, 21, 21, 21, 0, 0, london),
+		},
+	}
+	for _, test := range tests {
+		sunrise, sunset, ok := SunTimes(test.day, test.lat, test.long)
+		require.True(t, ok)
+		require.WithinDuration(t, test.sunrise, sunrise, 2*time.Minute)
+		require.WithinDuration(t, test.sunset, sunset, 2*time.Minute)
+		require.Equal(t, test.day.Location(), sunrise.Location())
+	}
+
+	// Polar night in Svalbard.
+	_, _, ok := SunTimes(time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), 78.2, 15.6)
+	require.False(t, ok)
+}
@@ -0,0 +1,157 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultTestHostPort is the port connected to for test hosts given without a
+// scheme or port.
+const DefaultTestHostPort = "443"
+
+var ErrNoConnection = errors.New("no test host could be reached")
+
+var hostnameRegex = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// testHostProbe checks a single test host can be reached.
+type testHostProbe func(ctx context.Context) error
+
+// Check tests for a connection by trying each of the URLs, which can be:
+//
+//	host or host:port            TCP connect, to DefaultTestHostPort if no port is given
+//	tcp://host:port              TCP connect
+//	dns://name                   Resolve the name
+//	http://... or https://...    HTTP HEAD request, any response counts
+//
+// Each attempt is given PingWaitTime and the URLs are tried up to PingRetries
+// times. It returns nil as soon as one succeeds, otherwise an error wrapping
+// ErrNoConnection and the error from each URL on the last try.
+func (th TestHosts) Check(ctx context.Context) error {
+	probes := make([]testHostProbe, len(th.URLs))
+	for i, u := range th.URLs {
+		probe, err := newTestHostProbe(u)
+		if err != nil {
+			return err
+		}
+		probes[i] = probe
+	}
+	if len(probes) == 0 {
+		return fmt.Errorf("%w: no test hosts are configured", ErrNoConnection)
+	}
+
+	tries := max(th.PingRetries, 1)
+	var errs []error
+	for range tries {
+		errs = []error{ErrNoConnection}
+		for i, probe := range probes {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			probeCtx, cancel := context.WithTimeout(ctx, th.PingWaitTime)
+			err := probe(probeCtx)
+			cancel()
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", th.URLs[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func newTestHostProbe(testHost string) (testHostProbe, error) {
+	if !strings.Contains(testHost, "://") {
+		address, err := bareTestHostAddress(testHost)
+		if err != nil {
+			return nil, err
+		}
+		return tcpProbe(address), nil
+	}
+
+	u, err := url.Parse(testHost)
+	if err != nil {
+		return nil, fmt.Errorf("invalid test host '%s': %w", testHost, err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("test host '%s' is missing a host", testHost)
+	}
+	switch u.Scheme {
+	case "tcp":
+		if u.Port() == "" {
+			return nil, fmt.Errorf("test host '%s' is missing a port", testHost)
+		}
+		return tcpProbe(u.Host), nil
+	case "dns":
+		return dnsProbe(u.Hostname()), nil
+	case "http", "https":
+		return httpProbe(u.String()), nil
+	default:
+		return nil, fmt.Errorf("test host '%s' has unsupported scheme '%s'", testHost, u.Scheme)
+	}
+}
+
+// bareTestHostAddress returns the address to connect to for a host, IP or host:port.
+func bareTestHostAddress(testHost string) (string, error) {
+	host, port, err := net.SplitHostPort(testHost)
+	if err != nil {
+		host, port = testHost, DefaultTestHostPort
+	}
+	if net.ParseIP(host) == nil && !hostnameRegex.MatchString(host) {
+		return "", fmt.Errorf("test host '%s' is not a valid host, IP or URL", testHost)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+func tcpProbe(address string) testHostProbe {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+func dnsProbe(name string) testHostProbe {
+	return func(ctx context.Context) error {
+		_, err := net.DefaultResolver.LookupHost(ctx, name)
+		return err
+	}
+}
+
+func httpProbe(rawURL string) testHostProbe {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// closedAddress returns a local address that nothing is listening on.
+func closedAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := l.Addr().String()
+	require.NoError(t, l.Close())
+	return address
+}
+
+func TestTestHostsCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodHead, r.Method)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	closed := closedAddress(t)
+	ctx := context.Background()
+	for _, tc := range []struct {
+		urls []string
+		ok   bool
+	}{
+		{[]string{l.Addr().String()}, true},
+		{[]string{"tcp://" + l.Addr().String()}, true},
+		{[]string{server.URL}, true},
+		{[]string{"dns://localhost"}, true},
+		{[]string{closed, "tcp://" + closed, "http://" + closed}, false},
+		{[]string{closed, server.URL}, true},
+		{[]string{"dns://no-such-host.invalid"}, false},
+	} {
+		th := TestHosts{URLs: tc.urls, PingWaitTime: time.Second, PingRetries: 2}
+		err := th.Check(ctx)
+		if tc.ok {
+			require.NoError(t, err, tc.urls)
+		} else {
+			require.ErrorIs(t, err, ErrNoConnection, tc.urls)
+		}
+	}
+
+	_, err = newTestHostProbe("ftp://example.com")
+	require.Error(t, err)
+	require.Error(t, TestHosts{URLs: []string{"not a host"}, PingWaitTime: time.Second}.Check(ctx))
+	require.ErrorIs(t, TestHosts{PingWaitTime: time.Second}.Check(ctx), ErrNoConnection)
+}
+
+func TestTestHostsCheckCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	th := TestHosts{URLs: []string{closedAddress(t)}, PingWaitTime: time.Second, PingRetries: 5}
+	require.ErrorIs(t, th.Check(ctx), context.Canceled)
+}
+
+func TestTestHostsValidation(t *testing.T) {
+	require.NoError(t, testHostsValidateFunc(DefaultTestHosts()))
+	require.NoError(t, testHostsValidateFunc(map[string]any{
+		"urls":           []string{"example.com", "192.168.1.1:53", "[::1]:80", "tcp://example.com:22", "dns://example.com", "https://example.com/status"},
+		"ping-wait-time": "30s",
+	}))
+
+	for _, urls := range [][]string{
+		{"not a host"},
+		{"-example.com"},
+		{"ftp://example.com"},
+		{"tcp://example.com"},
+		{"http://"},
+	} {
+		th := DefaultTestHosts()
+		th.URLs = urls
+		require.Error(t, testHostsValidateFunc(th), urls)
+	}
+	require.Error(t, testHostsValidateFunc(map[string]any{"ping-wait-time": "0s"}))
+	require.Error(t, testHostsValidateFunc(map[string]any{"ping-wait-time": "30s", "ping-retries": -1}))
+}
+
+func TestSetTestHosts(t *testing.T) {
+	defer newFs(t, "")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	// Partial maps are checked with the rest of the section as it is in the config.
+	require.NoError(t, conf.SetFromMap(TestHostsKey, map[string]any{"urls": []string{"example.com"}}, false))
+	require.Error(t, conf.SetFromMap(TestHostsKey, map[string]any{"ping-retries": -1}, false))
+	require.Error(t, conf.SetFromMap(TestHostsKey, map[string]any{"urls": []string{"ftp://example.com"}}, false))
+	require.NoError(t, conf.SetFromMap(TestHostsKey, map[string]any{"ping-wait-time": "10s"}, false))
+
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	th := TestHosts{}
+	require.NoError(t, conf.Unmarshal(TestHostsKey, &th))
+	require.Equal(t, TestHosts{URLs: []string{"example.com"}, PingWaitTime: 10 * time.Second}, th)
+}
@@ -16,7 +16,10 @@
 
 package config
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 const TestHostsKey = "test-hosts"
 
@@ -24,7 +27,7 @@ func init() {
 	allSections[TestHostsKey] = section{
 		key:         TestHostsKey,
 		mapToStruct: testHostsMapToStruct,
-		validate:    noValidateFunc,
+		validate:    testHostsValidateFunc,
 		defaultValue: func() interface{} {
 			return DefaultTestHosts()
 		},
@@ -55,3 +58,22 @@ func testHostsMapToStruct(m map[string]interface{}) (interface{}, error) {
 	}
 	return s, nil
 }
+
+func testHostsValidateFunc(testHosts any) error {
+	th, err := sectionStruct[TestHosts](TestHostsKey, testHosts)
+	if err != nil {
+		return err
+	}
+	if th.PingWaitTime <= 0 {
+		return fmt.Errorf("test hosts ping-wait-time must be greater than zero, got %s", th.PingWaitTime)
+	}
+	if th.PingRetries < 0 {
+		return fmt.Errorf("test hosts ping-retries can't be negative, got %d", th.PingRetries)
+	}
+	for _, u := range th.URLs {
+		if _, err := newTestHostProbe(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"math"
+)
+
+const kelvinOffset = 273.15
+
+// ThresholdProfile describes the units of the thermal-motion thresholds for a
+// camera model. A radiometric Lepton 3.5 reports centi-Kelvin so thresholds
+// can be converted to and from Celsius, older Leptons report uncalibrated
+// counts that can't.
+type ThresholdProfile struct {
+	Name        string
+	CameraModel string
+	Radiometric bool
+}
+
+// ThresholdProfileFor returns the threshold profile for a camera model.
+func ThresholdProfileFor(cameraModel string) ThresholdProfile {
+	switch cameraModel {
+	case CameraModelLepton35:
+		return ThresholdProfile{Name: "lepton3.5 centi-kelvin", CameraModel: cameraModel, Radiometric: true}
+	default:
+		return ThresholdProfile{Name: "lepton raw counts", CameraModel: cameraModel}
+	}
+}
+
+// ThresholdProfile returns the threshold profile for the camera model of the
+// config's hardware, which the thermal-motion defaults are given for.
+func (c *Config) ThresholdProfile() ThresholdProfile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ThresholdProfileFor(c.hardware.CameraModel)
+}
+
+// Defaults returns the thermal-motion defaults for the profile.
+func (p ThresholdProfile) Defaults() ThermalMotion {
+	return DefaultThermalMotion(p.CameraModel)
+}
+
+func (p ThresholdProfile) checkRadiometric() error {
+	if !p.Radiometric {
+		return fmt.Errorf("thresholds for '%s' are raw counts and can't be converted to temperatures", p.Name)
+	}
+	return nil
+}
+
+// RawToCelsius converts a raw temperature threshold to Celsius.
+func (p ThresholdProfile) RawToCelsius(raw uint16) (float64, error) {
+	if err := p.checkRadiometric(); err != nil {
+		return 0, err
+	}
+	return float64(raw)/100 - kelvinOffset, nil
+}
+
+// CelsiusToRaw converts a temperature in Celsius to a raw temperature threshold.
+func (p ThresholdProfile) CelsiusToRaw(celsius float64) (uint16, error) {
+	if err := p.checkRadiometric(); err != nil {
+		return 0, err
+	}
+	return centiKelvinToRaw((celsius + kelvinOffset) * 100)
+}
+
+// RawDeltaToKelvin converts a raw temperature difference threshold to Kelvin,
+// which is the same as a difference in Celsius.
+func (p ThresholdProfile) RawDeltaToKelvin(raw uint16) (float64, error) {
+	if err := p.checkRadiometric(); err != nil {
+		return 0, err
+	}
+	return float64(raw) / 100, nil
+}
+
+// KelvinDeltaToRaw converts a temperature difference in Kelvin to a raw threshold.
+func (p ThresholdProfile) KelvinDeltaToRaw(kelvin float64) (uint16, error) {
+	if err := p.checkRadiometric(); err != nil {
+		return 0, err
+	}
+	return centiKelvinToRaw(kelvin * 100)
+}
+
+func centiKelvinToRaw(centiKelvin float64) (uint16, error) {
+	raw := math.Round(centiKelvin)
+	if raw < 0 || raw > math.MaxUint16 {
+		return 0, fmt.Errorf("%.2fK is out of range", centiKelvin/100)
+	}
+	return uint16(raw), nil
+}
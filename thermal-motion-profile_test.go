@@ -0,0 +1,65 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThresholdProfileConversions(t *testing.T) {
+	p := ThresholdProfileFor(CameraModelLepton35)
+	require.True(t, p.Radiometric)
+	require.Equal(t, DefaultLepton35Motion(), p.Defaults())
+
+	c, err := p.RawToCelsius(28000)
+	require.NoError(t, err)
+	require.InDelta(t, 6.85, c, 0.001)
+	raw, err := p.CelsiusToRaw(6.85)
+	require.NoError(t, err)
+	require.Equal(t, uint16(28000), raw)
+
+	k, err := p.RawDeltaToKelvin(150)
+	require.NoError(t, err)
+	require.InDelta(t, 1.5, k, 0.001)
+	raw, err = p.KelvinDeltaToRaw(2)
+	require.NoError(t, err)
+	require.Equal(t, uint16(200), raw)
+
+	_, err = p.CelsiusToRaw(-300)
+	require.Error(t, err)
+	_, err = p.CelsiusToRaw(500)
+	require.Error(t, err)
+
+	p = ThresholdProfileFor(CameraModelLepton3)
+	require.False(t, p.Radiometric)
+	require.Equal(t, DefaultLeptonMotion(), p.Defaults())
+	_, err = p.RawToCelsius(2900)
+	require.Error(t, err)
+	_, err = p.KelvinDeltaToRaw(2)
+	require.Error(t, err)
+}
+
+func TestThermalMotionDefaultFromCameraModel(t *testing.T) {
+	defer newFs(t, "")()
+
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+	require.Equal(t, CameraModelLepton35, conf.ThresholdProfile().CameraModel)
+	require.Equal(t, DefaultLepton35Motion(), conf.GetDefaults()[ThermalMotionKey])
+
+	require.NoError(t, conf.SetFromMap(HardwareKey, map[string]any{"camera-model": CameraModelLepton3}, false))
+	require.Equal(t, CameraModelLepton3, conf.ThresholdProfile().CameraModel)
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	require.Equal(t, CameraModelLepton3, conf.ThresholdProfile().CameraModel)
+	require.Equal(t, DefaultLeptonMotion(), conf.GetDefaults()[ThermalMotionKey])
+
+	// The camera model in the lepton section takes precedence.
+	require.NoError(t, conf.SetFromMap(LeptonKey, map[string]any{"camera-model": CameraModelLepton35}, false))
+	require.Equal(t, CameraModelLepton35, conf.ThresholdProfile().CameraModel)
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	require.Equal(t, CameraModelLepton35, conf.ThresholdProfile().CameraModel)
+
+	require.Error(t, conf.SetFromMap(LeptonKey, map[string]any{"camera-model": "boson"}, false))
+}
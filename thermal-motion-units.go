@@ -0,0 +1,137 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var thresholdRegex = regexp.MustCompile(`^(-?[0-9]*\.?[0-9]+)\s*(delta-c|delta-k|c|k)$`)
+
+// thermal-motion keys that are temperatures, the others are differences
+var temperatureThresholdKeys = map[string]bool{
+	"temp-thresh":     true,
+	"temp-thresh-min": true,
+	"temp-thresh-max": true,
+}
+
+// ParseThreshold parses a thermal-motion threshold for the profile. It can be
+// a raw value ("28000"), a temperature ("7C" or "280K") or a temperature
+// difference ("2 delta-C" or "2 delta-K"). Units can only be used when the
+// profile is radiometric.
+func (p ThresholdProfile) ParseThreshold(s string) (uint16, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if raw, err := strconv.ParseUint(s, 10, 16); err == nil {
+		return uint16(raw), nil
+	}
+	match := thresholdRegex.FindStringSubmatch(s)
+	if match == nil {
+		return 0, fmt.Errorf("invalid threshold '%s', expected a raw value or a temperature such as '7C', '280K' or '2 delta-C'", s)
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	switch match[2] {
+	case "c":
+		return p.CelsiusToRaw(value)
+	case "k":
+		return p.CelsiusToRaw(value - kelvinOffset)
+	default:
+		return p.KelvinDeltaToRaw(value)
+	}
+}
+
+// FormatThreshold returns a raw threshold with its value in human units when
+// the profile is radiometric, e.g. "28000 (6.85C)".
+func (p ThresholdProfile) FormatThreshold(raw uint16, delta bool) string {
+	if delta {
+		if k, err := p.RawDeltaToKelvin(raw); err == nil {
+			return fmt.Sprintf("%d (%.2f delta-C)", raw, k)
+		}
+	} else if c, err := p.RawToCelsius(raw); err == nil {
+		return fmt.Sprintf("%d (%.2fC)", raw, c)
+	}
+	return strconv.Itoa(int(raw))
+}
+
+// FormatThresholds returns a "name: value" line for each threshold in tm,
+// formatted with FormatThreshold.
+func (p ThresholdProfile) FormatThresholds(tm ThermalMotion) []string {
+	return []string{
+		"temp-thresh: " + p.FormatThreshold(tm.TempThresh, false),
+		"temp-thresh-min: " + p.FormatThreshold(tm.TempThreshMin, false),
+		"temp-thresh-max: " + p.FormatThreshold(tm.TempThreshMax, false),
+		"delta-thresh: " + p.FormatThreshold(tm.DeltaThresh, true),
+	}
+}
+
+// thresholdHook decodes thresholds given as strings with units for the profile.
+func (p ThresholdProfile) thresholdHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if f.Kind() != reflect.String || t.Kind() != reflect.Uint16 {
+		return data, nil
+	}
+	return p.ParseThreshold(data.(string))
+}
+
+// parseThresholds returns a copy of the thermal-motion map with thresholds
+// given with units converted to raw values for the profile.
+func (p ThresholdProfile) parseThresholds(m map[string]interface{}) (map[string]interface{}, error) {
+	if err := checkThresholdUnits(m); err != nil {
+		return nil, err
+	}
+	parsed := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		lower := strings.ToLower(key)
+		if s, ok := value.(string); ok && (lower == "delta-thresh" || temperatureThresholdKeys[lower]) {
+			raw, err := p.ParseThreshold(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", lower, err)
+			}
+			value = raw
+		}
+		parsed[key] = value
+	}
+	return parsed, nil
+}
+
+// checkThresholdUnits checks temperatures aren't given as differences, or
+// differences as temperatures.
+func checkThresholdUnits(m map[string]interface{}) error {
+	for key, value := range m {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(key)
+		if key != "delta-thresh" && !temperatureThresholdKeys[key] {
+			continue
+		}
+		isDelta := strings.Contains(strings.ToLower(s), "delta")
+		if temperatureThresholdKeys[key] && isDelta {
+			return fmt.Errorf("%s is a temperature, not a temperature difference, got '%s'", key, s)
+		}
+		if key == "delta-thresh" && !isDelta && thresholdRegex.MatchString(strings.ToLower(strings.TrimSpace(s))) {
+			return fmt.Errorf("delta-thresh is a temperature difference, use delta-C or delta-K, got '%s'", s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseThreshold(t *testing.T) {
+	p := ThresholdProfileFor(CameraModelLepton35)
+	for s, expected := range map[string]uint16{
+		"28000":      28000,
+		"7C":         28015,
+		" 6.85 c ":   28000,
+		"280K":       28000,
+		"2 delta-C":  200,
+		"1.5delta-k": 150,
+	} {
+		raw, err := p.ParseThreshold(s)
+		require.NoError(t, err, s)
+		require.Equal(t, expected, raw, s)
+	}
+	for _, s := range []string{"7F", "warm", "-1", "70000", "-300C"} {
+		_, err := p.ParseThreshold(s)
+		require.Error(t, err, s)
+	}
+
+	p = ThresholdProfileFor(CameraModelLepton3)
+	raw, err := p.ParseThreshold("2900")
+	require.NoError(t, err)
+	require.Equal(t, uint16(2900), raw)
+	_, err = p.ParseThreshold("7C")
+	require.Error(t, err)
+
+	require.Equal(t, "28000 (6.85C)", ThresholdProfileFor(CameraModelLepton35).FormatThreshold(28000, false))
+	require.Equal(t, "150 (1.50 delta-C)", ThresholdProfileFor(CameraModelLepton35).FormatThreshold(150, true))
+	require.Equal(t, "2900", p.FormatThreshold(2900, false))
+	require.Equal(t, []string{
+		"temp-thresh: 2900",
+		"temp-thresh-min: 0",
+		"temp-thresh-max: 0",
+		"delta-thresh: 50",
+	}, p.FormatThresholds(ThermalMotion{TempThresh: 2900, DeltaThresh: 50}))
+}
+
+func TestThermalMotionThresholdUnits(t *testing.T) {
+	defer newFs(t, "")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	require.NoError(t, conf.SetFromMap(ThermalMotionKey, map[string]any{
+		"temp-thresh":  "7C",
+		"delta-thresh": "2 delta-C",
+	}, false))
+	require.EqualValues(t, 28015, conf.Get(ThermalMotionKey+".temp-thresh"))
+
+	tm := DefaultThermalMotion(CameraModelLepton35)
+	require.NoError(t, conf.Unmarshal(ThermalMotionKey, &tm))
+	require.Equal(t, uint16(28015), tm.TempThresh)
+	require.Equal(t, uint16(200), tm.DeltaThresh)
+
+	require.Error(t, conf.SetFromMap(ThermalMotionKey, map[string]any{"temp-thresh": "2 delta-C"}, false))
+	require.Error(t, conf.SetFromMap(ThermalMotionKey, map[string]any{"delta-thresh": "2C"}, false))
+	require.NoError(t, conf.SetField(ThermalMotionKey, "temp-thresh-max", "30C", false))
+	require.Error(t, conf.SetField(ThermalMotionKey, "temp-thresh-min", "10C", false))
+
+	// Partial maps are checked with the thresholds already in the config.
+	require.Error(t, conf.SetFromMap(ThermalMotionKey, map[string]any{"temp-thresh": "31C"}, false))
+	require.Error(t, conf.SetFromMap(ThermalMotionKey, map[string]any{"temp-thresh-min": "10C"}, false))
+	require.NoError(t, conf.SetFromMap(ThermalMotionKey, map[string]any{"temp-thresh-min": "5C"}, false))
+
+	// Set stores the raw value too, not the string with units.
+	require.NoError(t, conf.Set(ThermalMotionKey, map[string]any{"temp-thresh": "6C"}))
+	conf, err = New(DefaultConfigDir)
+	require.NoError(t, err)
+	require.EqualValues(t, 27915, conf.Get(ThermalMotionKey+".temp-thresh"))
+}
+
+func TestThermalMotionThresholdUnitsOnlyForThermalMotion(t *testing.T) {
+	defer newFs(t, "")()
+	require.NoError(t, afero.WriteFile(fs, DefaultConfigDir+"/"+ConfigFileName,
+		[]byte("[lepton]\n  spi-speed = \"7C\"\n"), 0644))
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	var l struct {
+		SPISpeed uint16 `mapstructure:"spi-speed"`
+	}
+	require.Error(t, conf.Unmarshal(LeptonKey, &l))
+}
+
+func TestThermalMotionThresholdUnitsInFile(t *testing.T) {
+	defer newFs(t, "")()
+	require.NoError(t, afero.WriteFile(fs, DefaultConfigDir+"/"+ConfigFileName,
+		[]byte("[thermal-motion]\n  temp-thresh = \"280K\"\n  delta-thresh = \"1.5 delta-C\"\n"), 0644))
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	tm := DefaultThermalMotion(CameraModelLepton35)
+	require.NoError(t, conf.Unmarshal(ThermalMotionKey, &tm))
+	require.Equal(t, uint16(28000), tm.TempThresh)
+	require.Equal(t, uint16(150), tm.DeltaThresh)
+}
+
+func TestThermalMotionValidation(t *testing.T) {
+	tm := DefaultLepton35Motion()
+	require.NoError(t, thermalMotionValidateFunc(tm))
+
+	tm.TempThreshMin = 27000
+	tm.TempThreshMax = 30000
+	require.NoError(t, thermalMotionValidateFunc(tm))
+
+	tm.TempThreshMin = 29000
+	require.Error(t, thermalMotionValidateFunc(tm))
+
+	tm.TempThreshMin = 0
+	tm.TempThreshMax = 27000
+	require.Error(t, thermalMotionValidateFunc(tm))
+
+	// Only checked for a dynamic threshold
+	tm.DynamicThreshold = false
+	require.NoError(t, thermalMotionValidateFunc(tm))
+}
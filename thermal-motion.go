@@ -16,15 +16,20 @@
 
 package config
 
+import "fmt"
+
 const ThermalMotionKey = "thermal-motion"
 
 func init() {
 	allSections[ThermalMotionKey] = section{
 		key:         ThermalMotionKey,
 		mapToStruct: thermalMotionMapToStruct,
-		validate:    noValidateFunc,
+		validate:    thermalMotionValidateFunc,
 		defaultValue: func() interface{} {
-			return DefaultThermalMotion("lepton3.5")
+			return DefaultThermalMotion(DefaultHardware().CameraModel)
+		},
+		hardwareDefault: func(h Hardware) interface{} {
+			return DefaultThermalMotion(h.CameraModel)
 		},
 		pointerValue: func() interface{} {
 			return &ThermalMotion{}
@@ -34,8 +39,8 @@ func init() {
 
 type ThermalMotion struct {
 	DynamicThreshold  bool   `mapstructure:"dynamic-threshold"`
-	TempThreshMin     uint16 `mapstructure:"temp-thresh-min"`
-	TempThreshMax     uint16 `mapstructure:"temp-thresh-max"`
+	TempThreshMin     uint16 `mapstructure:"temp-thresh-min"` // Lower bound for a dynamic threshold, 0 for none
+	TempThreshMax     uint16 `mapstructure:"temp-thresh-max"` // Upper bound for a dynamic threshold, 0 for none
 	TempThresh        uint16 `mapstructure:"temp-thresh"`
 	DeltaThresh       uint16 `mapstructure:"delta-thresh"`
 	CountThresh       int    `mapstructure:"count-thresh"`
@@ -55,7 +60,7 @@ type ThermalMotion struct {
 
 func DefaultThermalMotion(cameraModel string) ThermalMotion {
 	switch cameraModel {
-	case "lepton3.5":
+	case CameraModelLepton35:
 		return DefaultLepton35Motion()
 	default:
 		return DefaultLeptonMotion()
@@ -92,6 +97,8 @@ func DefaultLeptonMotion() ThermalMotion {
 	}
 }
 
+// Thresholds given with units are converted by the config, see
+// ThresholdProfile.ParseThreshold.
 func thermalMotionMapToStruct(m map[string]interface{}) (interface{}, error) {
 	var s ThermalMotion
 	if err := decodeStructFromMap(&s, m, nil); err != nil {
@@ -99,3 +106,20 @@ func thermalMotionMapToStruct(m map[string]interface{}) (interface{}, error) {
 	}
 	return s, nil
 }
+
+func thermalMotionValidateFunc(thermalMotion any) error {
+	tm, err := sectionStruct[ThermalMotion](ThermalMotionKey, thermalMotion)
+	if err != nil {
+		return err
+	}
+	if !tm.DynamicThreshold {
+		return nil
+	}
+	if tm.TempThreshMin != 0 && tm.TempThreshMin > tm.TempThresh {
+		return fmt.Errorf("temp-thresh-min (%d) must not be more than temp-thresh (%d)", tm.TempThreshMin, tm.TempThresh)
+	}
+	if tm.TempThreshMax != 0 && tm.TempThresh > tm.TempThreshMax {
+		return fmt.Errorf("temp-thresh (%d) must not be more than temp-thresh-max (%d)", tm.TempThresh, tm.TempThreshMax)
+	}
+	return nil
+}
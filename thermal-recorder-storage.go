@@ -0,0 +1,71 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// EstimatedRecordingBytesPerSecond is roughly how much space a CPTV recording
+// takes, used to estimate how many recordings fit in the free space.
+var EstimatedRecordingBytesPerSecond uint64 = 50 * 1024
+
+// Statfs returns the free space available to users and the total size of the
+// filesystem holding path.
+type Statfs func(path string) (free, total uint64, err error)
+
+// DefaultStatfs is Statfs using the statfs system call.
+func DefaultStatfs(path string) (uint64, uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), st.Blocks * uint64(st.Bsize), nil
+}
+
+// RecorderStorage is the free space for thermal recordings.
+type RecorderStorage struct {
+	FreeBytes           uint64
+	TotalBytes          uint64
+	MinFreeBytes        uint64 // From MinDiskSpaceMB
+	Sufficient          bool   // If there is more than MinFreeBytes free so recordings can be made
+	RecordingsRemaining uint64 // Estimated number of MaxSecs recordings that fit before reaching MinFreeBytes
+}
+
+// StorageStatus reports the free space on the filesystem of OutputDir against
+// MinDiskSpaceMB. DefaultStatfs is used if statfs is nil.
+func (tr ThermalRecorder) StorageStatus(statfs Statfs) (RecorderStorage, error) {
+	if statfs == nil {
+		statfs = DefaultStatfs
+	}
+	free, total, err := statfs(tr.OutputDir)
+	if err != nil {
+		return RecorderStorage{}, err
+	}
+
+	s := RecorderStorage{
+		FreeBytes:    free,
+		TotalBytes:   total,
+		MinFreeBytes: tr.MinDiskSpaceMB * 1024 * 1024,
+	}
+	s.Sufficient = s.FreeBytes > s.MinFreeBytes
+	recordingBytes := uint64(max(tr.MaxSecs, 1)) * EstimatedRecordingBytesPerSecond
+	if s.Sufficient && recordingBytes > 0 {
+		s.RecordingsRemaining = (s.FreeBytes - s.MinFreeBytes) / recordingBytes
+	}
+	return s, nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThermalRecorderStorageStatus(t *testing.T) {
+	const mb = 1024 * 1024
+	tr := DefaultThermalRecorder()
+	var statfsPath string
+	statfs := func(free uint64) Statfs {
+		return func(path string) (uint64, uint64, error) {
+			statfsPath = path
+			return free, 1000 * mb, nil
+		}
+	}
+
+	recordingBytes := uint64(tr.MaxSecs) * EstimatedRecordingBytesPerSecond
+	s, err := tr.StorageStatus(statfs(200*mb + 3*recordingBytes + 1))
+	require.NoError(t, err)
+	require.Equal(t, tr.OutputDir, statfsPath)
+	require.True(t, s.Sufficient)
+	require.Equal(t, uint64(200*mb), s.MinFreeBytes)
+	require.Equal(t, uint64(1000*mb), s.TotalBytes)
+	require.Equal(t, uint64(3), s.RecordingsRemaining)
+
+	s, err = tr.StorageStatus(statfs(100 * mb))
+	require.NoError(t, err)
+	require.False(t, s.Sufficient)
+	require.Zero(t, s.RecordingsRemaining)
+
+	_, err = tr.StorageStatus(func(string) (uint64, uint64, error) { return 0, 0, errors.New("no disk") })
+	require.Error(t, err)
+
+	// The real statfs works on any existing directory.
+	tr.OutputDir = t.TempDir()
+	s, err = tr.StorageStatus(nil)
+	require.NoError(t, err)
+	require.NotZero(t, s.TotalBytes)
+}
+
+func TestThermalRecorderValidation(t *testing.T) {
+	require.NoError(t, thermalRecorderValidateFunc(DefaultThermalRecorder()))
+	require.NoError(t, thermalRecorderValidateFunc(map[string]any{
+		"output-dir": "/var/spool/cptv", "min-secs": 10, "max-secs": 321, "preview-secs": 5,
+	}))
+
+	for name, modify := range map[string]func(tr *ThermalRecorder){
+		"relative output dir": func(tr *ThermalRecorder) { tr.OutputDir = "cptv" },
+		"negative preview":    func(tr *ThermalRecorder) { tr.PreviewSecs = -1 },
+		"preview over min":    func(tr *ThermalRecorder) { tr.PreviewSecs = 10 },
+		"min over max":        func(tr *ThermalRecorder) { tr.MinSecs, tr.MaxSecs = 60, 30 },
+	} {
+		tr := DefaultThermalRecorder()
+		modify(&tr)
+		require.Error(t, thermalRecorderValidateFunc(tr), name)
+	}
+}
+
+func TestSetThermalRecorder(t *testing.T) {
+	defer newFs(t, "")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	// Partial maps are checked with the rest of the section as it is in the config.
+	require.Error(t, conf.SetFromMap(ThermalRecorderKey, map[string]any{"max-secs": 5}, false))
+	require.NoError(t, conf.SetFromMap(ThermalRecorderKey, map[string]any{"min-secs": 30}, false))
+	require.Error(t, conf.SetFromMap(ThermalRecorderKey, map[string]any{"max-secs": 20}, false))
+	require.NoError(t, conf.SetFromMap(ThermalRecorderKey, map[string]any{"preview-secs": 20}, false))
+}
@@ -16,7 +16,11 @@
 
 package config
 
-import "time"
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
 
 const ThermalRecorderKey = "thermal-recorder"
 
@@ -24,7 +28,7 @@ func init() {
 	allSections[ThermalRecorderKey] = section{
 		key:         ThermalRecorderKey,
 		mapToStruct: thermalRecorderMapToStruct,
-		validate:    noValidateFunc,
+		validate:    thermalRecorderValidateFunc,
 		defaultValue: func() interface{} {
 			return DefaultThermalRecorder()
 		},
@@ -67,3 +71,23 @@ func thermalRecorderMapToStruct(m map[string]interface{}) (interface{}, error) {
 	}
 	return s, nil
 }
+
+func thermalRecorderValidateFunc(thermalRecorder any) error {
+	tr, err := sectionStruct[ThermalRecorder](ThermalRecorderKey, thermalRecorder)
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(tr.OutputDir) {
+		return fmt.Errorf("thermal recorder output-dir must be an absolute path, got '%s'", tr.OutputDir)
+	}
+	if tr.PreviewSecs < 0 {
+		return fmt.Errorf("thermal recorder preview-secs can't be negative, got %d", tr.PreviewSecs)
+	}
+	if tr.PreviewSecs >= tr.MinSecs {
+		return fmt.Errorf("thermal recorder preview-secs (%d) must be less than min-secs (%d)", tr.PreviewSecs, tr.MinSecs)
+	}
+	if tr.MinSecs > tr.MaxSecs {
+		return fmt.Errorf("thermal recorder min-secs (%d) must not be more than max-secs (%d)", tr.MinSecs, tr.MaxSecs)
+	}
+	return nil
+}
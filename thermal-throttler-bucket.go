@@ -0,0 +1,201 @@
+// go-config - Library for reading cacophony config files.
+// Copyright (C) 2018, The Cacophony Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+const DefaultThrottlerStateFile = "/var/lib/cacophony/thermal-throttler.json"
+
+// ThrottlerState is the bucket state saved across restarts
+type ThrottlerState struct {
+	Available time.Duration `json:"available"` // Recording time left in the bucket
+	Throttled bool          `json:"throttled"` // Emptied and waiting for MinRefill
+	Updated   time.Time     `json:"updated"`
+}
+
+// Throttler is a token bucket of recording time using the thermal-throttler
+// settings.
+//
+// The bucket holds up to BucketSize of recording time. Recording drains it and
+// it refills at the same rate while not recording. Once it is empty recording
+// is throttled until MinRefill has refilled, so a busy camera makes fewer long
+// recordings rather than many short ones.
+type Throttler struct {
+	settings  ThermalThrottler
+	clock     func() time.Time
+	statePath string
+	state     ThrottlerState
+	recording bool
+	mu        sync.Mutex
+}
+
+// NewThrottler creates a Throttler with a full bucket, or the state saved at
+// statePath if there is one. The clock is time.Now if nil and no state is
+// saved if statePath is empty.
+func NewThrottler(settings ThermalThrottler, statePath string, clock func() time.Time) (*Throttler, error) {
+	if err := thermalThrottlerValidateFunc(settings); err != nil {
+		return nil, err
+	}
+	if clock == nil {
+		clock = time.Now
+	}
+	t := &Throttler{
+		settings:  settings,
+		clock:     clock,
+		statePath: statePath,
+		state:     ThrottlerState{Available: settings.BucketSize, Updated: clock()},
+	}
+
+	state, err := loadThrottlerState(statePath)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
+		t.state = *state
+		t.state.Available = min(t.state.Available, settings.BucketSize)
+		if t.state.Updated.After(t.clock()) {
+			t.state.Updated = t.clock() // Clock went backwards, don't refill for it
+		}
+	}
+	return t, nil
+}
+
+func loadThrottlerState(statePath string) (*ThrottlerState, error) {
+	if statePath == "" {
+		return nil, nil
+	}
+	b, err := afero.ReadFile(fs, statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	state := &ThrottlerState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("failed to parse throttler state file %s: %w", statePath, err)
+	}
+	return state, nil
+}
+
+// update drains or refills the bucket up to the current time.
+func (t *Throttler) update() {
+	now := t.clock()
+	elapsed := now.Sub(t.state.Updated)
+	t.state.Updated = now
+	if elapsed <= 0 {
+		return
+	}
+	if t.recording {
+		t.state.Available -= elapsed
+		if t.state.Available <= 0 {
+			t.state.Available = 0
+			t.state.Throttled = true
+		}
+		return
+	}
+	t.state.Available = min(t.state.Available+elapsed, t.settings.BucketSize)
+	if t.state.Throttled && t.state.Available >= t.settings.MinRefill {
+		t.state.Throttled = false
+	}
+}
+
+// CanRecord returns if a new recording may start.
+func (t *Throttler) CanRecord() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.update()
+	return t.canRecord()
+}
+
+func (t *Throttler) canRecord() bool {
+	return !t.settings.Activate || (!t.state.Throttled && t.state.Available > 0)
+}
+
+// StartRecording starts draining the bucket and returns if the recording may
+// start. Nothing changes if it may not.
+func (t *Throttler) StartRecording() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.update()
+	if !t.canRecord() {
+		return false
+	}
+	t.recording = true
+	return true
+}
+
+// Recording returns if the current recording may continue, it may not once
+// the bucket is empty.
+func (t *Throttler) Recording() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.update()
+	return t.recording && t.canRecord()
+}
+
+// StopRecording stops draining the bucket and saves the state.
+func (t *Throttler) StopRecording() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.update()
+	t.recording = false
+	return t.save()
+}
+
+// Available returns the recording time left in the bucket.
+func (t *Throttler) Available() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.update()
+	return t.state.Available
+}
+
+// State returns the current bucket state.
+func (t *Throttler) State() ThrottlerState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.update()
+	return t.state
+}
+
+// Save writes the bucket state to the state file.
+func (t *Throttler) Save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.update()
+	return t.save()
+}
+
+func (t *Throttler) save() error {
+	if t.statePath == "" {
+		return nil
+	}
+	b, err := json.Marshal(t.state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(t.statePath, b, 0644)
+}
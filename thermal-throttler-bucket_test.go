@@ -0,0 +1,111 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottler(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	throttler, err := NewThrottler(DefaultThermalThrottler(), "", clock.now)
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Minute, throttler.Available())
+
+	require.True(t, throttler.StartRecording())
+	clock.advance(4 * time.Minute)
+	require.True(t, throttler.Recording())
+	require.NoError(t, throttler.StopRecording())
+	require.Equal(t, 6*time.Minute, throttler.Available())
+
+	// Refills while not recording, up to the bucket size.
+	clock.advance(time.Minute)
+	require.Equal(t, 7*time.Minute, throttler.Available())
+	clock.advance(time.Hour)
+	require.Equal(t, 10*time.Minute, throttler.Available())
+
+	// Emptying the bucket stops the recording.
+	require.True(t, throttler.StartRecording())
+	clock.advance(11 * time.Minute)
+	require.False(t, throttler.Recording())
+	require.NoError(t, throttler.StopRecording())
+	require.Zero(t, throttler.Available())
+	require.True(t, throttler.State().Throttled)
+
+	// Recording can't start again until MinRefill has refilled.
+	clock.advance(9 * time.Minute)
+	require.False(t, throttler.CanRecord())
+	require.False(t, throttler.StartRecording())
+	clock.advance(time.Minute)
+	require.True(t, throttler.CanRecord())
+}
+
+func TestThrottlerNotActive(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	settings := DefaultThermalThrottler()
+	settings.Activate = false
+	throttler, err := NewThrottler(settings, "", clock.now)
+	require.NoError(t, err)
+
+	require.True(t, throttler.StartRecording())
+	clock.advance(time.Hour)
+	require.True(t, throttler.Recording())
+	require.Zero(t, throttler.Available())
+}
+
+func TestThrottlerPersistence(t *testing.T) {
+	defer newFs(t, "")()
+	const statePath = "/var/lib/cacophony/thermal-throttler.json"
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	throttler, err := NewThrottler(DefaultThermalThrottler(), statePath, clock.now)
+	require.NoError(t, err)
+	require.True(t, throttler.StartRecording())
+	clock.advance(10 * time.Minute)
+	require.NoError(t, throttler.StopRecording())
+
+	// A restart keeps the empty bucket, and refills for the time it was down.
+	clock.advance(2 * time.Minute)
+	throttler, err = NewThrottler(DefaultThermalThrottler(), statePath, clock.now)
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Minute, throttler.Available())
+	require.False(t, throttler.CanRecord())
+
+	// A smaller bucket caps the saved state.
+	require.NoError(t, throttler.Save())
+	clock.advance(time.Hour)
+	settings := DefaultThermalThrottler()
+	settings.BucketSize = 5 * time.Minute
+	settings.MinRefill = time.Minute
+	throttler, err = NewThrottler(settings, statePath, clock.now)
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Minute, throttler.Available())
+}
+
+func TestNewThrottlerErrors(t *testing.T) {
+	_, err := NewThrottler(ThermalThrottler{Activate: true}, "", nil)
+	require.Error(t, err)
+	_, err = NewThrottler(ThermalThrottler{Activate: true, BucketSize: time.Minute, MinRefill: time.Hour}, "", nil)
+	require.Error(t, err)
+}
+
+func TestThermalThrottlerValidation(t *testing.T) {
+	require.NoError(t, thermalThrottlerValidateFunc(DefaultThermalThrottler()))
+	require.NoError(t, thermalThrottlerValidateFunc(map[string]any{"bucket-size": "20m", "min-refill": "10m"}))
+	require.Error(t, thermalThrottlerValidateFunc(map[string]any{"bucket-size": "5m", "min-refill": "10m"}))
+	require.Error(t, thermalThrottlerValidateFunc(map[string]any{"bucket-size": "20m", "min-refill": "-1m"}))
+	require.Error(t, thermalThrottlerValidateFunc(map[string]any{"min-refill": "1m"}))
+}
+
+func TestSetThermalThrottler(t *testing.T) {
+	defer newFs(t, "")()
+	conf, err := New(DefaultConfigDir)
+	require.NoError(t, err)
+
+	// Partial maps are checked with the rest of the section as it is in the config.
+	require.Error(t, conf.SetFromMap(ThermalThrottlerKey, map[string]any{"bucket-size": "5m"}, false))
+	require.NoError(t, conf.SetFromMap(ThermalThrottlerKey, map[string]any{"min-refill": "2m"}, false))
+	require.NoError(t, conf.SetFromMap(ThermalThrottlerKey, map[string]any{"bucket-size": "5m"}, false))
+	require.Error(t, conf.SetFromMap(ThermalThrottlerKey, map[string]any{"min-refill": "6m"}, false))
+}
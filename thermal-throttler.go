@@ -16,7 +16,10 @@
 
 package config
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 const ThermalThrottlerKey = "thermal-throttler"
 
@@ -24,7 +27,7 @@ func init() {
 	allSections[ThermalThrottlerKey] = section{
 		key:         ThermalThrottlerKey,
 		mapToStruct: thermalThrottlerMapToStruct,
-		validate:    noValidateFunc,
+		validate:    thermalThrottlerValidateFunc,
 		defaultValue: func() interface{} {
 			return DefaultThermalThrottler()
 		},
@@ -56,3 +59,17 @@ func thermalThrottlerMapToStruct(m map[string]interface{}) (interface{}, error)
 	}
 	return s, nil
 }
+
+func thermalThrottlerValidateFunc(thermalThrottler any) error {
+	tt, err := sectionStruct[ThermalThrottler](ThermalThrottlerKey, thermalThrottler)
+	if err != nil {
+		return err
+	}
+	if tt.BucketSize <= 0 {
+		return fmt.Errorf("thermal throttler bucket-size must be greater than zero, got %s", tt.BucketSize)
+	}
+	if tt.MinRefill < 0 || tt.MinRefill > tt.BucketSize {
+		return fmt.Errorf("thermal throttler min-refill must be between 0 and bucket-size (%s), got %s", tt.BucketSize, tt.MinRefill)
+	}
+	return nil
+}